@@ -5,18 +5,23 @@ import (
 	"fmt"
 	"golang.org/x/image/draw" // Extended draw package
 	"image"
+	"image/color"
+	"main/to_image"
 )
 
 // MergeWithDraw vertically merges a slice of images using the extended draw package
-func MergeWithDraw(images []image.Image) (image.Image, error) {
+func MergeWithDraw(images []image.Image, geometry to_image.LayoutOptions) (image.Image, error) {
+	return MergeWithDrawKernel(images, geometry, draw.NearestNeighbor)
+}
+
+// MergeWithDrawKernel is MergeWithDraw scaling the cards with kernel
+func MergeWithDrawKernel(images []image.Image, geometry to_image.LayoutOptions, kernel draw.Interpolator) (image.Image, error) {
 	if len(images) == 0 {
 		return nil, fmt.Errorf("no images provided")
 	}
 
-	cardWidth := 1012
-	cardHeight := 638
-	margin := 30
-	sideMargin := 60
+	cardWidth, cardHeight := geometry.CardWidth, geometry.CardHeight
+	margin, sideMargin := geometry.Gutter, geometry.Padding
 
 	totalHeight := (cardHeight * len(images)) + (margin * (len(images) - 1)) + (sideMargin * 2)
 	totalWidth := cardWidth + (sideMargin * 2)
@@ -24,8 +29,8 @@ func MergeWithDraw(images []image.Image) (image.Image, error) {
 	// Create a new RGBA image to hold the merged result
 	mergedImg := image.NewRGBA(image.Rect(0, 0, totalWidth, totalHeight))
 
-	// Fill with white background (use standard draw for this simple operation)
-	draw.Draw(mergedImg, mergedImg.Bounds(), image.White, image.Point{}, draw.Src)
+	// Fill with the background (use standard draw for this simple operation)
+	draw.Draw(mergedImg, mergedImg.Bounds(), background(geometry), image.Point{}, draw.Src)
 
 	currentY := sideMargin
 	for _, img := range images {
@@ -48,7 +53,7 @@ func MergeWithDraw(images []image.Image) (image.Image, error) {
 		xPos := sideMargin + ((cardWidth - newWidth) / 2)
 		yPos := currentY + ((cardHeight - newHeight) / 2)
 
-		draw.NearestNeighbor.Scale(
+		kernel.Scale(
 			mergedImg,
 			image.Rect(xPos, yPos, xPos+newWidth, yPos+newHeight),
 			img,
@@ -62,3 +67,12 @@ func MergeWithDraw(images []image.Image) (image.Image, error) {
 
 	return mergedImg, nil
 }
+
+// background returns the fill of the merged image, white when geometry has
+// none. Corner rounding is left to to_image and not benchmarked.
+func background(geometry to_image.LayoutOptions) image.Image {
+	if geometry.Background == nil {
+		return image.White
+	}
+	return image.NewUniform(color.RGBAModel.Convert(geometry.Background))
+}
@@ -5,26 +5,25 @@ import (
 	"fmt"
 	"image"
 	"image/draw"
+	"main/to_image"
 
 	"github.com/sunshineplan/imgconv"
 )
 
 // MergeWithImgconv vertically merges a slice of images using imgconv for resizing
-func MergeWithImgconv(images []image.Image) (image.Image, error) {
+func MergeWithImgconv(images []image.Image, geometry to_image.LayoutOptions) (image.Image, error) {
 	if len(images) == 0 {
 		return nil, fmt.Errorf("no images provided")
 	}
 
-	cardWidth := 1012
-	cardHeight := 638
-	margin := 30
-	sideMargin := 60
+	cardWidth, cardHeight := geometry.CardWidth, geometry.CardHeight
+	margin, sideMargin := geometry.Gutter, geometry.Padding
 
 	totalHeight := (cardHeight * len(images)) + (margin * (len(images) - 1)) + (sideMargin * 2)
 	totalWidth := cardWidth + (sideMargin * 2)
 	mergedImg := image.NewRGBA(image.Rect(0, 0, totalWidth, totalHeight))
 
-	draw.Draw(mergedImg, mergedImg.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(mergedImg, mergedImg.Bounds(), background(geometry), image.Point{}, draw.Src)
 
 	currentY := sideMargin
 	for _, img := range images {
@@ -0,0 +1,85 @@
+// image_merging/resample.go
+package image_merging
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// Kernel is a resampling kernel compared by the resampling benchmark; names
+// match the interpolator query parameter of /image/idcards
+type Kernel struct {
+	Name         string
+	Interpolator draw.Interpolator
+}
+
+// Kernels are the kernels to_image can scale cards with, fastest first
+var Kernels = []Kernel{
+	{"nearest", draw.NearestNeighbor},
+	{"approx-bilinear", draw.ApproxBiLinear},
+	{"bilinear", draw.BiLinear},
+	{"catmull-rom", draw.CatmullRom},
+}
+
+// ScaleToFit scales img with kernel to the largest size that fits in width ×
+// height, keeping its aspect ratio
+func ScaleToFit(img image.Image, width, height int, kernel draw.Interpolator) *image.RGBA {
+	bounds := img.Bounds()
+	ratio := math.Min(float64(width)/float64(bounds.Dx()), float64(height)/float64(bounds.Dy()))
+	dst := image.NewRGBA(image.Rect(0, 0, int(float64(bounds.Dx())*ratio), int(float64(bounds.Dy())*ratio)))
+	kernel.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// AreaAverage downscales img to the size of dst by averaging every source
+// pixel under each destination pixel. It is too slow to serve but is the
+// reference the kernels are measured against.
+func AreaAverage(img image.Image, width, height int) *image.RGBA {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sx := float64(bounds.Dx()) / float64(width)
+	sy := float64(bounds.Dy()) / float64(height)
+	for y := 0; y < height; y++ {
+		y0, y1 := span(y, sy)
+		for x := 0; x < width; x++ {
+			x0, x1 := span(x, sx)
+			var r, g, b, a, n uint64
+			for v := y0; v < y1; v++ {
+				for u := x0; u < x1; u++ {
+					pr, pg, pb, pa := img.At(bounds.Min.X+u, bounds.Min.Y+v).RGBA()
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// span returns the source pixels covered by destination pixel i at scale s
+func span(i int, s float64) (int, int) {
+	lo := int(float64(i) * s)
+	hi := int(float64(i+1) * s)
+	if hi <= lo {
+		hi = lo + 1
+	}
+	return lo, hi
+}
+
+// PSNR is the peak signal-to-noise ratio of a against b in dB, higher is
+// closer; identical images give +Inf
+func PSNR(a, b *image.RGBA) float64 {
+	var sum float64
+	for i := range a.Pix {
+		if i%4 == 3 {
+			continue // alpha
+		}
+		d := float64(a.Pix[i]) - float64(b.Pix[i])
+		sum += d * d
+	}
+	mse := sum / float64(len(a.Pix)/4*3)
+	return 10 * math.Log10(255*255/mse)
+}
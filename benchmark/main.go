@@ -3,11 +3,14 @@ package main
 import (
 	"flag"
 	"fmt"
+	"image"
 	"log"
 	"main/benchmark/data"
 	"main/benchmark/image_merging"
 	"main/benchmark/pdf_generation"
 	"main/benchmark/pdf_to_image"
+	"main/browser_pool"
+	"main/to_image"
 	"runtime"
 	"sort"
 	"time"
@@ -72,6 +75,15 @@ func runPdfGenerationBenchmarks(mockData data.MockData) {
 	})
 	printResults(results["chromedp"])
 
+	// chromedp benchmark reusing one browser across iterations
+	fmt.Println("- chromedp (pooled):")
+	pool := browser_pool.New(browser_pool.Config{Size: 1})
+	results["chromedp-pool"] = benchmark(func() ([]byte, error) {
+		return pdf_generation.GenerateWithChromedpPool(pool, mockData.IdCards)
+	})
+	pool.Close()
+	printResults(results["chromedp-pool"])
+
 	// Compare results
 	compareResults("PDF Generation", results)
 	fmt.Println()
@@ -133,6 +145,7 @@ func runImageMergingBenchmarks() {
 	fmt.Println("=== Image Merging Benchmarks ===")
 
 	results := make(map[string]benchmarkResult)
+	geometry := to_image.DefaultLayoutOptions(to_image.DefaultDPI)
 
 	// imaging benchmark
 	fmt.Println("- imaging:")
@@ -148,7 +161,7 @@ func runImageMergingBenchmarks() {
 	// imgconv benchmark
 	fmt.Println("- imgconv:")
 	results["imgconv"] = benchmark(func() ([]byte, error) {
-		img, err := image_merging.MergeWithImgconv(images)
+		img, err := image_merging.MergeWithImgconv(images, geometry)
 		if err != nil {
 			return nil, err
 		}
@@ -159,7 +172,7 @@ func runImageMergingBenchmarks() {
 	// standard library draw benchmark
 	fmt.Println("- standard library (draw):")
 	results["standard draw"] = benchmark(func() ([]byte, error) {
-		img, err := image_merging.MergeWithDraw(images)
+		img, err := image_merging.MergeWithDraw(images, geometry)
 		if err != nil {
 			return nil, err
 		}
@@ -170,6 +183,72 @@ func runImageMergingBenchmarks() {
 	// Compare results
 	compareResults("Image Merging", results)
 	fmt.Println()
+
+	runResamplingBenchmarks(images, geometry)
+}
+
+// runResamplingBenchmarks reports the speed of merging with each resampling
+// kernel against how close its cards come to an area-average downscale
+func runResamplingBenchmarks(images []image.Image, geometry to_image.LayoutOptions) {
+	fmt.Println("=== Resampling Quality/Speed ===")
+
+	cardWidth, cardHeight := geometry.CardWidth, geometry.CardHeight
+	references := make([]*image.RGBA, len(images))
+	for i, img := range images {
+		size := image_merging.ScaleToFit(img, cardWidth, cardHeight, image_merging.Kernels[0].Interpolator).Bounds().Size()
+		references[i] = image_merging.AreaAverage(img, size.X, size.Y)
+	}
+
+	type kernelResult struct {
+		name   string
+		result benchmarkResult
+		psnr   float64
+	}
+	var kernelResults []kernelResult
+	for _, kernel := range image_merging.Kernels {
+		fmt.Printf("- %s:\n", kernel.Name)
+		result := benchmark(func() ([]byte, error) {
+			img, err := image_merging.MergeWithDrawKernel(images, geometry, kernel.Interpolator)
+			if err != nil {
+				return nil, err
+			}
+			return pdf_to_image.EncodeImage(img)
+		})
+		printResults(result)
+
+		var psnr float64
+		for i, img := range images {
+			psnr += image_merging.PSNR(image_merging.ScaleToFit(img, cardWidth, cardHeight, kernel.Interpolator), references[i])
+		}
+		psnr /= float64(len(images))
+		fmt.Printf("  Quality: %.2f dB PSNR against an area-average downscale\n", psnr)
+		kernelResults = append(kernelResults, kernelResult{name: kernel.Name, result: result, psnr: psnr})
+	}
+
+	fastest, sharpest := kernelResults[0], kernelResults[0]
+	for _, r := range kernelResults {
+		if r.result.duration < fastest.result.duration {
+			fastest = r
+		}
+		if r.psnr > sharpest.psnr {
+			sharpest = r
+		}
+	}
+
+	fmt.Printf("\n=== Resampling Comparison ===\n")
+	fmt.Printf("  %-16s %14s %10s %12s\n", "Kernel", "Time/op", "PSNR", "Output size")
+	for _, r := range kernelResults {
+		perOp := r.result.duration / time.Duration(r.result.iterations)
+		fmt.Printf("  %-16s %14v %7.2f dB %9.2f KB\n", r.name, perOp, r.psnr, float64(r.result.bytesSize)/1024)
+	}
+	if fastest.name != sharpest.name {
+		slower := float64(sharpest.result.duration-fastest.result.duration) / float64(fastest.result.duration) * 100
+		fmt.Printf("\n  %s is the closest to the reference (%.2f dB better than %s) and %.1f%% slower than %s\n",
+			sharpest.name, sharpest.psnr-fastest.psnr, fastest.name, slower, fastest.name)
+	} else {
+		fmt.Printf("\n  %s is both the fastest and the closest to the reference\n", fastest.name)
+	}
+	fmt.Println()
 }
 
 type benchmarkResult struct {
@@ -0,0 +1,89 @@
+package browser_pool
+
+import (
+	"context"
+	"fmt"
+
+	cdpbrowser "github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/chromedp"
+)
+
+// chromeBrowser is a headless Chrome process started through chromedp
+type chromeBrowser struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	allocCancel context.CancelFunc
+}
+
+func launchChrome(opts []chromedp.ExecAllocatorOption) (browser, error) {
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	ctx, cancel := chromedp.NewContext(allocCtx)
+	// The first Run starts the browser; it must not carry a timeout or the
+	// browser is stopped when it expires
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		allocCancel()
+		return nil, fmt.Errorf("failed to start browser: %w", err)
+	}
+	return &chromeBrowser{ctx: ctx, cancel: cancel, allocCancel: allocCancel}, nil
+}
+
+func (b *chromeBrowser) newTab() (tab, error) {
+	ctx, cancel := chromedp.NewContext(b.ctx)
+	// Open the tab now so a render's deadline cannot close it
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+	return &chromeTab{ctx: ctx, cancel: cancel}, nil
+}
+
+func (b *chromeBrowser) ping(ctx context.Context) error {
+	c := chromedp.FromContext(b.ctx)
+	if c == nil || c.Browser == nil {
+		return fmt.Errorf("browser not running")
+	}
+	_, _, _, _, _, err := cdpbrowser.GetVersion().Do(cdp.WithExecutor(ctx, c.Browser))
+	return err
+}
+
+func (b *chromeBrowser) close() {
+	b.cancel()
+	b.allocCancel()
+}
+
+// chromeTab is a single Chrome tab
+type chromeTab struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (t *chromeTab) run(ctx context.Context, actions []chromedp.Action) error {
+	// Cancelling a context derived from the tab stops the actions but keeps
+	// the tab open
+	runCtx, cancel := context.WithCancel(t.ctx)
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); ok {
+		runCtx, cancel = context.WithDeadline(runCtx, deadline)
+		defer cancel()
+	}
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	if err := chromedp.Run(runCtx, actions...); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("%w: %v", ctxErr, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (t *chromeTab) ping(ctx context.Context) error {
+	return t.run(ctx, []chromedp.Action{chromedp.Evaluate("1", nil)})
+}
+
+func (t *chromeTab) close() {
+	t.cancel()
+}
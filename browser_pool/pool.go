@@ -0,0 +1,305 @@
+package browser_pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+var (
+	// ErrBusy is returned when no tab frees up within Config.QueueTimeout
+	ErrBusy = errors.New("browser pool busy")
+	// ErrClosed is returned by Run once the pool is closed
+	ErrClosed = errors.New("browser pool closed")
+)
+
+// Config controls the size and upkeep of a Pool
+type Config struct {
+	// Size is the maximum number of tabs rendering at the same time
+	Size int
+	// MaxRendersPerTab closes a tab after that many renders so whatever a
+	// page leaks does not pile up
+	MaxRendersPerTab int
+	// QueueTimeout bounds how long Run waits for a free tab before failing
+	// with ErrBusy
+	QueueTimeout time.Duration
+	// HealthCheckInterval is how often the browser and idle tabs are checked
+	HealthCheckInterval time.Duration
+	// AllocatorOptions configure the Chrome process;
+	// chromedp.DefaultExecAllocatorOptions are used when nil
+	AllocatorOptions []chromedp.ExecAllocatorOption
+}
+
+// DefaultConfig is used for the zero fields of the Config passed to New
+var DefaultConfig = Config{
+	Size:                4,
+	MaxRendersPerTab:    50,
+	QueueTimeout:        30 * time.Second,
+	HealthCheckInterval: 30 * time.Second,
+}
+
+// pingTimeout bounds a single health check
+const pingTimeout = 5 * time.Second
+
+// browser is a running browser process that tabs are opened in
+type browser interface {
+	newTab() (tab, error)
+	ping(ctx context.Context) error
+	close()
+}
+
+// tab is a browser tab that runs actions for one render at a time
+type tab interface {
+	run(ctx context.Context, actions []chromedp.Action) error
+	ping(ctx context.Context) error
+	close()
+}
+
+// pooledTab is a tab together with its bookkeeping
+type pooledTab struct {
+	tab
+	renders    int
+	generation int // browser generation the tab was opened in
+}
+
+// Pool shares one long-lived headless Chrome between renders. At most
+// Config.Size tabs render at once; callers beyond that wait for a free tab.
+type Pool struct {
+	cfg    Config
+	launch func() (browser, error)
+	slots  chan struct{}
+	done   chan struct{}
+	wg     sync.WaitGroup
+
+	mu         sync.Mutex
+	browser    browser
+	generation int
+	idle       []*pooledTab
+	closed     bool
+	launching  chan struct{} // closed once the browser being launched is up or failed
+}
+
+// New creates a pool. Chrome is started on the first render and restarted
+// when a health check finds it gone.
+func New(cfg Config) *Pool {
+	opts := cfg.AllocatorOptions
+	if opts == nil {
+		opts = chromedp.DefaultExecAllocatorOptions[:]
+	}
+	return newPool(cfg, func() (browser, error) {
+		return launchChrome(opts)
+	})
+}
+
+func newPool(cfg Config, launch func() (browser, error)) *Pool {
+	if cfg.Size <= 0 {
+		cfg.Size = DefaultConfig.Size
+	}
+	if cfg.MaxRendersPerTab <= 0 {
+		cfg.MaxRendersPerTab = DefaultConfig.MaxRendersPerTab
+	}
+	if cfg.QueueTimeout <= 0 {
+		cfg.QueueTimeout = DefaultConfig.QueueTimeout
+	}
+	if cfg.HealthCheckInterval <= 0 {
+		cfg.HealthCheckInterval = DefaultConfig.HealthCheckInterval
+	}
+
+	p := &Pool{
+		cfg:    cfg,
+		launch: launch,
+		slots:  make(chan struct{}, cfg.Size),
+		done:   make(chan struct{}),
+	}
+	p.wg.Add(1)
+	go p.healthLoop()
+	return p
+}
+
+// Run runs actions in a pooled tab. It waits for a free tab for at most
+// Config.QueueTimeout and stops the actions when ctx is done.
+func (p *Pool) Run(ctx context.Context, actions ...chromedp.Action) error {
+	queueTimer := time.NewTimer(p.cfg.QueueTimeout)
+	defer queueTimer.Stop()
+
+	select {
+	case p.slots <- struct{}{}:
+	case <-queueTimer.C:
+		return ErrBusy
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.done:
+		return ErrClosed
+	}
+	defer func() { <-p.slots }()
+
+	t, err := p.take()
+	if err != nil {
+		return err
+	}
+	err = t.run(ctx, actions)
+	p.put(t, err)
+	return err
+}
+
+// take returns an idle tab or opens a new one, starting the browser if needed.
+// Chrome is launched and the tab opened without holding p.mu, so put, Close
+// and the health check are not held up by a slow browser start; renders that
+// need the browser meanwhile wait for the launch in progress.
+func (p *Pool) take() (*pooledTab, error) {
+	p.mu.Lock()
+	for p.browser == nil && p.launching != nil && !p.closed {
+		launching := p.launching
+		p.mu.Unlock()
+		<-launching
+		p.mu.Lock()
+	}
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if n := len(p.idle); n > 0 {
+		t := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return t, nil
+	}
+
+	b, generation := p.browser, p.generation
+	if b == nil {
+		launching := make(chan struct{})
+		p.launching = launching
+		p.mu.Unlock()
+
+		launched, err := p.launch()
+
+		p.mu.Lock()
+		p.launching = nil
+		close(launching)
+		if err != nil {
+			p.mu.Unlock()
+			return nil, err
+		}
+		if p.closed {
+			p.mu.Unlock()
+			launched.close()
+			return nil, ErrClosed
+		}
+		p.browser = launched
+		p.generation++
+		b, generation = launched, p.generation
+	}
+	p.mu.Unlock()
+
+	t, err := b.newTab()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tab: %w", err)
+	}
+	return &pooledTab{tab: t, generation: generation}, nil
+}
+
+// put hands a tab back after a render. Tabs that failed, reached
+// MaxRendersPerTab or belong to a replaced browser are closed.
+func (p *Pool) put(t *pooledTab, renderErr error) {
+	t.renders++
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if renderErr != nil || t.renders >= p.cfg.MaxRendersPerTab || t.generation != p.generation || p.closed {
+		t.close()
+		return
+	}
+	p.idle = append(p.idle, t)
+}
+
+// healthLoop checks the browser and idle tabs until the pool is closed
+func (p *Pool) healthLoop() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.cfg.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.checkHealth()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// checkHealth restarts a browser that stopped answering and closes idle tabs
+// that no longer respond
+func (p *Pool) checkHealth() {
+	p.mu.Lock()
+	b, idle := p.browser, p.idle
+	p.idle = nil
+	p.mu.Unlock()
+	if b == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	if err := b.ping(ctx); err != nil {
+		log.Printf("Browser failed health check, restarting on next render: %v", err)
+		for _, t := range idle {
+			t.close()
+		}
+		p.mu.Lock()
+		if p.browser == b {
+			p.browser = nil
+			p.generation++
+		}
+		p.mu.Unlock()
+		b.close()
+		return
+	}
+
+	var healthy []*pooledTab
+	for _, t := range idle {
+		if err := t.ping(ctx); err != nil {
+			log.Printf("Browser tab failed health check: %v", err)
+			t.close()
+			continue
+		}
+		healthy = append(healthy, t)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		for _, t := range healthy {
+			t.close()
+		}
+		return
+	}
+	p.idle = append(p.idle, healthy...)
+}
+
+// Close closes every tab and stops the browser. Renders in progress fail.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.done)
+	idle, b := p.idle, p.browser
+	p.idle, p.browser = nil, nil
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	for _, t := range idle {
+		t.close()
+	}
+	if b != nil {
+		b.close()
+	}
+}
@@ -0,0 +1,310 @@
+package browser_pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// fakeBrowser hands out fake tabs and counts how often it was launched
+type fakeBrowser struct {
+	mu      sync.Mutex
+	tabs    []*fakeTab
+	pingErr error
+	closed  bool
+}
+
+func (b *fakeBrowser) newTab() (tab, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := &fakeTab{}
+	b.tabs = append(b.tabs, t)
+	return t, nil
+}
+
+func (b *fakeBrowser) ping(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.pingErr
+}
+
+func (b *fakeBrowser) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+}
+
+func (b *fakeBrowser) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+func (b *fakeBrowser) tabCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.tabs)
+}
+
+// fakeTab runs nothing; block, when set, holds every run until it is closed
+type fakeTab struct {
+	mu      sync.Mutex
+	runs    int
+	pingErr error
+	closed  bool
+	block   chan struct{}
+}
+
+func (t *fakeTab) run(ctx context.Context, actions []chromedp.Action) error {
+	t.mu.Lock()
+	t.runs++
+	block := t.block
+	t.mu.Unlock()
+	if block != nil {
+		select {
+		case <-block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (t *fakeTab) ping(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pingErr
+}
+
+func (t *fakeTab) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+}
+
+func (t *fakeTab) runCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.runs
+}
+
+func (t *fakeTab) isClosed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
+
+// newFakePool returns a pool whose browsers are fakes; the last launched
+// browser is available through the returned function
+func newFakePool(t *testing.T, cfg Config) (*Pool, func() *fakeBrowser, *atomic.Int32) {
+	var launches atomic.Int32
+	var mu sync.Mutex
+	var last *fakeBrowser
+	p := newPool(cfg, func() (browser, error) {
+		launches.Add(1)
+		b := &fakeBrowser{}
+		mu.Lock()
+		last = b
+		mu.Unlock()
+		return b, nil
+	})
+	t.Cleanup(p.Close)
+	return p, func() *fakeBrowser {
+		mu.Lock()
+		defer mu.Unlock()
+		return last
+	}, &launches
+}
+
+func TestPoolReusesTabs(t *testing.T) {
+	p, browser, launches := newFakePool(t, Config{Size: 2})
+
+	for i := 0; i < 5; i++ {
+		if err := p.Run(context.Background()); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	}
+	if launches.Load() != 1 {
+		t.Errorf("browser launched %d times, want 1", launches.Load())
+	}
+	if n := browser().tabCount(); n != 1 {
+		t.Errorf("opened %d tabs for sequential renders, want 1", n)
+	}
+}
+
+func TestPoolRecyclesTabs(t *testing.T) {
+	p, browser, _ := newFakePool(t, Config{Size: 1, MaxRendersPerTab: 2})
+
+	for i := 0; i < 5; i++ {
+		if err := p.Run(context.Background()); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	}
+
+	b := browser()
+	if n := b.tabCount(); n != 3 {
+		t.Fatalf("opened %d tabs, want 3", n)
+	}
+	for i, tab := range b.tabs[:2] {
+		if !tab.isClosed() || tab.runCount() != 2 {
+			t.Errorf("tab %d: closed = %v, runs = %d, want closed after 2 runs", i, tab.isClosed(), tab.runCount())
+		}
+	}
+}
+
+func TestPoolBackPressure(t *testing.T) {
+	p, browser, _ := newFakePool(t, Config{Size: 1, QueueTimeout: 50 * time.Millisecond})
+
+	// Warm up a tab and make it block the next render
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	release := make(chan struct{})
+	tab := browser().tabs[0]
+	tab.mu.Lock()
+	tab.block = release
+	tab.mu.Unlock()
+
+	started := make(chan error)
+	go func() {
+		started <- p.Run(context.Background())
+	}()
+	// Wait until the blocking render holds the only tab
+	for deadline := time.Now().Add(time.Second); tab.runCount() < 2; {
+		if time.Now().After(deadline) {
+			t.Fatal("blocking render did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := p.Run(context.Background()); !errors.Is(err, ErrBusy) {
+		t.Errorf("Run() on a busy pool error = %v, want %v", err, ErrBusy)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() with cancelled context error = %v, want %v", err, context.Canceled)
+	}
+
+	close(release)
+	if err := <-started; err != nil {
+		t.Errorf("blocked Run() error = %v", err)
+	}
+}
+
+func TestPoolHealthCheck(t *testing.T) {
+	p, browser, launches := newFakePool(t, Config{Size: 2})
+
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// A tab that stops answering is closed
+	b := browser()
+	b.tabs[0].mu.Lock()
+	b.tabs[0].pingErr = errors.New("tab crashed")
+	b.tabs[0].mu.Unlock()
+	p.checkHealth()
+	if !b.tabs[0].isClosed() {
+		t.Error("unhealthy tab was not closed")
+	}
+
+	// A browser that stops answering is replaced on the next render
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	b.mu.Lock()
+	b.pingErr = errors.New("browser crashed")
+	b.mu.Unlock()
+	p.checkHealth()
+	if !b.isClosed() || !b.tabs[1].isClosed() {
+		t.Error("unhealthy browser and its tabs were not closed")
+	}
+
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if launches.Load() != 2 {
+		t.Errorf("browser launched %d times, want 2", launches.Load())
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	p, browser, _ := newFakePool(t, Config{})
+
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	p.Close()
+
+	b := browser()
+	if !b.isClosed() || !b.tabs[0].isClosed() {
+		t.Error("Close() left the browser or its tabs open")
+	}
+	if err := p.Run(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("Run() after Close() error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestPoolLaunchOutsideLock(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	var launches atomic.Int32
+	var launched *fakeBrowser
+	p := newPool(Config{Size: 2}, func() (browser, error) {
+		launches.Add(1)
+		started <- struct{}{}
+		<-release
+		launched = &fakeBrowser{}
+		return launched, nil
+	})
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { errs <- p.Run(context.Background()) }()
+	}
+	<-started
+
+	// The pool stays usable while Chrome starts
+	locked := make(chan struct{})
+	go func() {
+		p.mu.Lock()
+		p.mu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("pool lock held during browser launch")
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		p.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close() waited for the browser launch")
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; !errors.Is(err, ErrClosed) {
+			t.Errorf("Run() error = %v, want %v", err, ErrClosed)
+		}
+	}
+	if n := launches.Load(); n != 1 {
+		t.Errorf("browser launched %d times, want once", n)
+	}
+	if !launched.isClosed() {
+		t.Error("browser launched after Close() was left running")
+	}
+}
@@ -0,0 +1,50 @@
+package card_error
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Stage is the processing step at which a card failed
+type Stage string
+
+const (
+	// StageFetch is downloading a remote card image
+	StageFetch Stage = "fetch"
+	// StageDecode is decoding the card image bytes
+	StageDecode Stage = "decode"
+	// StageRender is rendering an HTML card
+	StageRender Stage = "render"
+	// StageRasterize is converting a rendered HTML card to an image
+	StageRasterize Stage = "rasterize"
+)
+
+// CardError describes why a single card is missing from the output
+type CardError struct {
+	CardId string
+	Stage  Stage
+	Err    error
+}
+
+func (e *CardError) Error() string {
+	return fmt.Sprintf("card %s failed at %s: %v", e.CardId, e.Stage, e.Err)
+}
+
+func (e *CardError) Unwrap() error {
+	return e.Err
+}
+
+// CardsError is returned when cards could not be included in the output,
+// either in strict mode or because no card succeeded at all
+type CardsError struct {
+	Cards []*CardError
+	Total int
+}
+
+func (e *CardsError) Error() string {
+	msgs := make([]string, 0, len(e.Cards))
+	for _, cardErr := range e.Cards {
+		msgs = append(msgs, cardErr.Error())
+	}
+	return fmt.Sprintf("%d of %d cards failed: %s", len(e.Cards), e.Total, strings.Join(msgs, "; "))
+}
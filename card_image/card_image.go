@@ -0,0 +1,233 @@
+package card_image
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"net/url"
+	"strings"
+
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+	"github.com/sunshineplan/imgconv"
+)
+
+// Media types of the card image formats that are accepted
+const (
+	PNG  = "image/png"
+	JPEG = "image/jpeg"
+	GIF  = "image/gif"
+	WebP = "image/webp"
+	BMP  = "image/bmp"
+	TIFF = "image/tiff"
+	SVG  = "image/svg+xml"
+)
+
+// Errors returned for card image sources that cannot be used
+var (
+	ErrUnknownFormat = errors.New("unknown image format, expected PNG, JPEG, GIF, WebP, BMP, TIFF or SVG")
+	ErrEmpty         = errors.New("image source is empty")
+	ErrDataURI       = errors.New("malformed data URI")
+	ErrSVGSize       = errors.New("SVG has no width, height or viewBox")
+)
+
+// SVGWidth is the width SVG cards are rasterised at, about the width of the
+// PNG cards carriers send. The height follows the SVG's aspect ratio.
+const SVGWidth = 1400
+
+// signature is a magic number at the start of an image file; '?' in pattern
+// matches any byte
+type signature struct {
+	pattern   string
+	mediaType string
+}
+
+var signatures = []signature{
+	{"\x89PNG\r\n\x1a\n", PNG},
+	{"\xff\xd8\xff", JPEG},
+	{"GIF87a", GIF},
+	{"GIF89a", GIF},
+	{"RIFF????WEBP", WebP},
+	{"BM", BMP},
+	{"II*\x00", TIFF},
+	{"MM\x00*", TIFF},
+}
+
+// svgSniffLen is how far into a text payload the <svg> element is looked for,
+// leaving room for an XML declaration, comments and a doctype
+const svgSniffLen = 1024
+
+// Sniff returns the media type of an image from its content, ignoring any type
+// a carrier declared for it
+func Sniff(content []byte) (string, error) {
+	for _, sig := range signatures {
+		if matchSignature(content, sig.pattern) {
+			return sig.mediaType, nil
+		}
+	}
+	if isSVG(content) {
+		return SVG, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+func matchSignature(content []byte, pattern string) bool {
+	if len(content) < len(pattern) {
+		return false
+	}
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '?' && pattern[i] != content[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func isSVG(content []byte) bool {
+	head := bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	head = bytes.TrimLeft(head, " \t\r\n")
+	if len(head) > svgSniffLen {
+		head = head[:svgSniffLen]
+	}
+	return bytes.HasPrefix(head, []byte("<")) && bytes.Contains(bytes.ToLower(head), []byte("<svg"))
+}
+
+// DecodeSource returns the image bytes of a card source, which is either plain
+// base64 or a data URI. The declared media type of a data URI is ignored and
+// the content is sniffed instead.
+func DecodeSource(source string) ([]byte, string, error) {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return nil, "", ErrEmpty
+	}
+
+	var content []byte
+	var err error
+	if strings.HasPrefix(source, "data:") {
+		content, err = decodeDataURI(source)
+	} else {
+		content, err = decodeBase64(source)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	mediaType, err := Sniff(content)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, mediaType, nil
+}
+
+// decodeDataURI decodes the payload of a data URI, either base64 or percent
+// encoded as is common for SVG
+func decodeDataURI(uri string) ([]byte, error) {
+	header, payload, ok := strings.Cut(strings.TrimPrefix(uri, "data:"), ",")
+	if !ok {
+		return nil, ErrDataURI
+	}
+	if strings.HasSuffix(strings.ToLower(header), ";base64") {
+		return decodeBase64(payload)
+	}
+	text, err := url.PathUnescape(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDataURI, err)
+	}
+	return []byte(text), nil
+}
+
+// decodeBase64 accepts padded and unpadded base64 with line breaks, as
+// produced by the various encoders carriers use
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, s)
+	content, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		var rawErr error
+		if content, rawErr = base64.RawStdEncoding.DecodeString(s); rawErr != nil {
+			return nil, fmt.Errorf("invalid base64 image: %w", err)
+		}
+	}
+	return content, nil
+}
+
+// DataURI encodes image content as a data URI with its sniffed media type, so
+// browsers render it whatever the carrier declared
+func DataURI(content []byte) (string, error) {
+	mediaType, err := Sniff(content)
+	if err != nil {
+		return "", err
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(content), nil
+}
+
+// Decode decodes image content of any accepted format, rasterising SVG
+func Decode(content []byte) (image.Image, error) {
+	mediaType, err := Sniff(content)
+	if err != nil {
+		return nil, err
+	}
+	if mediaType == SVG {
+		return rasterizeSVG(content)
+	}
+	return imgconv.Decode(bytes.NewReader(content))
+}
+
+// svgSize is the size an SVG is rasterised at
+func svgSize(icon *oksvg.SvgIcon) (int, int, error) {
+	if icon.ViewBox.W <= 0 || icon.ViewBox.H <= 0 {
+		return 0, 0, ErrSVGSize
+	}
+	h := math.Max(1, math.Round(icon.ViewBox.H*SVGWidth/icon.ViewBox.W))
+	if h > math.MaxInt32 {
+		return 0, 0, ErrSVGSize
+	}
+	return SVGWidth, int(h), nil
+}
+
+// rasterizeSVG draws an SVG at SVGWidth on a transparent background
+func rasterizeSVG(content []byte) (image.Image, error) {
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(content), oksvg.IgnoreErrorMode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SVG: %w", err)
+	}
+	w, h, err := svgSize(icon)
+	if err != nil {
+		return nil, err
+	}
+	icon.SetTarget(0, 0, float64(w), float64(h))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	scanner := rasterx.NewScannerGV(w, h, img, img.Bounds())
+	icon.Draw(rasterx.NewDasher(w, h, scanner), 1)
+	return img, nil
+}
+
+// DecodeConfig returns the dimensions content decodes to without decoding it.
+// SVGs report the size they are rasterised at.
+func DecodeConfig(content []byte) (image.Config, error) {
+	mediaType, err := Sniff(content)
+	if err != nil {
+		return image.Config{}, err
+	}
+	if mediaType == SVG {
+		icon, err := oksvg.ReadIconStream(bytes.NewReader(content), oksvg.IgnoreErrorMode)
+		if err != nil {
+			return image.Config{}, fmt.Errorf("invalid SVG: %w", err)
+		}
+		w, h, err := svgSize(icon)
+		if err != nil {
+			return image.Config{}, err
+		}
+		return image.Config{ColorModel: color.RGBAModel, Width: w, Height: h}, nil
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(content))
+	return cfg, err
+}
@@ -0,0 +1,150 @@
+package card_image
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sunshineplan/imgconv"
+)
+
+const testSVG = `<?xml version="1.0" encoding="UTF-8"?>
+<!-- Carrier logo -->
+<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 50">
+  <rect x="0" y="0" width="100" height="50" fill="#ff0000"/>
+</svg>`
+
+// encode returns a 20x10 red image in the given format
+func encode(t *testing.T, format imgconv.Format) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 20; x++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := imgconv.Write(&buf, img, &imgconv.FormatOption{Format: format}); err != nil {
+		t.Fatalf("imgconv.Write(%v) error = %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+func TestSniffAndDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+		size    image.Point
+	}{
+		{"png", encode(t, imgconv.PNG), PNG, image.Pt(20, 10)},
+		{"jpeg", encode(t, imgconv.JPEG), JPEG, image.Pt(20, 10)},
+		{"gif", encode(t, imgconv.GIF), GIF, image.Pt(20, 10)},
+		{"webp", encode(t, imgconv.WEBP), WebP, image.Pt(20, 10)},
+		{"bmp", encode(t, imgconv.BMP), BMP, image.Pt(20, 10)},
+		{"tiff", encode(t, imgconv.TIFF), TIFF, image.Pt(20, 10)},
+		{"svg", []byte(testSVG), SVG, image.Pt(SVGWidth, SVGWidth/2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Sniff(tt.content)
+			if err != nil || got != tt.want {
+				t.Fatalf("Sniff() = %q, %v, want %q", got, err, tt.want)
+			}
+
+			img, err := Decode(tt.content)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if size := img.Bounds().Size(); size != tt.size {
+				t.Errorf("Decode() size = %v, want %v", size, tt.size)
+			}
+			r, _, _, a := img.At(img.Bounds().Dx()/2, img.Bounds().Dy()/2).RGBA()
+			if r>>8 < 200 || a>>8 != 255 {
+				t.Errorf("Decode() centre pixel is not red")
+			}
+		})
+	}
+}
+
+func TestSniffRejectsUnknown(t *testing.T) {
+	for _, content := range [][]byte{
+		nil,
+		[]byte("%PDF-1.7\n"),
+		[]byte("<html><body>card</body></html>"),
+		[]byte("RIFF\x00\x00\x00\x00WAVE"),
+	} {
+		if got, err := Sniff(content); !errors.Is(err, ErrUnknownFormat) {
+			t.Errorf("Sniff(%q) = %q, %v, want ErrUnknownFormat", content, got, err)
+		}
+	}
+}
+
+func TestDecodeSource(t *testing.T) {
+	jpeg := encode(t, imgconv.JPEG)
+	b64 := base64.StdEncoding.EncodeToString(jpeg)
+
+	tests := []struct {
+		name   string
+		source string
+		want   []byte
+		media  string
+	}{
+		{"base64", b64, jpeg, JPEG},
+		{"unpadded base64", strings.TrimRight(b64, "="), jpeg, JPEG},
+		{"wrapped base64", b64[:40] + "\n" + b64[40:], jpeg, JPEG},
+		// The declared type is wrong, the bytes decide
+		{"data URI", "data:image/png;base64," + b64, jpeg, JPEG},
+		{"percent encoded SVG", "data:image/svg+xml," + url.PathEscape(testSVG), []byte(testSVG), SVG},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, media, err := DecodeSource(tt.source)
+			if err != nil {
+				t.Fatalf("DecodeSource() error = %v", err)
+			}
+			if !bytes.Equal(content, tt.want) || media != tt.media {
+				t.Errorf("DecodeSource() = %d bytes of %q, want %d bytes of %q", len(content), media, len(tt.want), tt.media)
+			}
+		})
+	}
+
+	for source, want := range map[string]error{
+		"":                      ErrEmpty,
+		"data:image/png;base64": ErrDataURI,
+		base64.StdEncoding.EncodeToString([]byte("plain text")): ErrUnknownFormat,
+	} {
+		if _, _, err := DecodeSource(source); !errors.Is(err, want) {
+			t.Errorf("DecodeSource(%q) error = %v, want %v", source, err, want)
+		}
+	}
+	if _, _, err := DecodeSource("not base64!"); err == nil {
+		t.Error("DecodeSource() accepted invalid base64")
+	}
+}
+
+func TestDataURI(t *testing.T) {
+	gif := encode(t, imgconv.GIF)
+	got, err := DataURI(gif)
+	if err != nil {
+		t.Fatalf("DataURI() error = %v", err)
+	}
+	if want := "data:image/gif;base64," + base64.StdEncoding.EncodeToString(gif); got != want {
+		t.Errorf("DataURI() = %.40q..., want %.40q...", got, want)
+	}
+	if _, err := DataURI([]byte("plain text")); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("DataURI() error = %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestDecodeSVGWithoutSize(t *testing.T) {
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg"><rect width="10" height="10"/></svg>`)
+	if _, err := Decode(svg); !errors.Is(err, ErrSVGSize) {
+		t.Errorf("Decode() error = %v, want ErrSVGSize", err)
+	}
+}
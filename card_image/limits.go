@@ -0,0 +1,127 @@
+package card_image
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sync"
+)
+
+// Errors returned when card images or the image they are merged into are too
+// big
+var (
+	ErrCardLimit    = errors.New("card image exceeds the size limit")
+	ErrRequestLimit = errors.New("card images exceed the size limit of the request")
+	ErrCanvasLimit  = errors.New("merged image exceeds the size limit")
+)
+
+// Limits caps what is decoded, so a small compressed file that expands to a
+// huge bitmap cannot exhaust the server's memory. Pixel counts are width ×
+// height; a decoded image takes about 4 bytes per pixel.
+type Limits struct {
+	// MaxCardBytes caps the encoded size of one card image
+	MaxCardBytes int64
+	// MaxCardPixels caps the decoded size of one card image
+	MaxCardPixels int64
+	// MaxRequestBytes caps the encoded size of all card images of a request
+	MaxRequestBytes int64
+	// MaxRequestPixels caps the decoded size of all card images of a request
+	MaxRequestPixels int64
+	// MaxCanvasPixels caps the size of the image the cards are merged into,
+	// which grows with the card count, the card size and the DPI
+	MaxCanvasPixels int64
+}
+
+// DefaultLimits are used for limits that are not set. A card may be up to
+// 4096 × 4096 pixels, ten times the size carriers usually send.
+var DefaultLimits = Limits{
+	MaxCardBytes:     10 << 20,
+	MaxCardPixels:    4096 * 4096,
+	MaxRequestBytes:  50 << 20,
+	MaxRequestPixels: 4 * 4096 * 4096,
+	MaxCanvasPixels:  4 * 4096 * 4096,
+}
+
+// WithDefaults fills the unset limits from DefaultLimits
+func (l Limits) WithDefaults() Limits {
+	if l.MaxCardBytes <= 0 {
+		l.MaxCardBytes = DefaultLimits.MaxCardBytes
+	}
+	if l.MaxCardPixels <= 0 {
+		l.MaxCardPixels = DefaultLimits.MaxCardPixels
+	}
+	if l.MaxRequestBytes <= 0 {
+		l.MaxRequestBytes = DefaultLimits.MaxRequestBytes
+	}
+	if l.MaxRequestPixels <= 0 {
+		l.MaxRequestPixels = DefaultLimits.MaxRequestPixels
+	}
+	if l.MaxCanvasPixels <= 0 {
+		l.MaxCanvasPixels = DefaultLimits.MaxCanvasPixels
+	}
+	return l
+}
+
+// CheckCanvas fails with ErrCanvasLimit when an image of width × height
+// pixels is bigger than MaxCanvasPixels
+func (l Limits) CheckCanvas(width, height int64) error {
+	limit := l.WithDefaults().MaxCanvasPixels
+	if width > 0 && height > limit/width {
+		return fmt.Errorf("%w: %d × %d pixels, at most %d allowed", ErrCanvasLimit, width, height, limit)
+	}
+	return nil
+}
+
+// Budget tracks the card images decoded for one request against its Limits.
+// It is safe for concurrent use.
+type Budget struct {
+	limits Limits
+
+	mu     sync.Mutex
+	bytes  int64
+	pixels int64
+}
+
+// NewBudget returns an empty budget for a request
+func NewBudget(limits Limits) *Budget {
+	return &Budget{limits: limits.WithDefaults()}
+}
+
+// Check reads the dimensions of content without decoding it and charges it to
+// the budget. It fails with ErrCardLimit when the image alone is too big and
+// with ErrRequestLimit when the request has used up its budget.
+func (b *Budget) Check(content []byte) (image.Config, error) {
+	size := int64(len(content))
+	if size > b.limits.MaxCardBytes {
+		return image.Config{}, fmt.Errorf("%w: %d bytes, at most %d allowed", ErrCardLimit, size, b.limits.MaxCardBytes)
+	}
+
+	cfg, err := DecodeConfig(content)
+	if err != nil {
+		return image.Config{}, err
+	}
+	pixels := int64(cfg.Width) * int64(cfg.Height)
+	if pixels > b.limits.MaxCardPixels {
+		return image.Config{}, fmt.Errorf("%w: %d × %d pixels, at most %d allowed", ErrCardLimit, cfg.Width, cfg.Height, b.limits.MaxCardPixels)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.bytes+size > b.limits.MaxRequestBytes {
+		return image.Config{}, fmt.Errorf("%w: more than %d bytes", ErrRequestLimit, b.limits.MaxRequestBytes)
+	}
+	if b.pixels+pixels > b.limits.MaxRequestPixels {
+		return image.Config{}, fmt.Errorf("%w: more than %d pixels", ErrRequestLimit, b.limits.MaxRequestPixels)
+	}
+	b.bytes += size
+	b.pixels += pixels
+	return cfg, nil
+}
+
+// Decode checks content against the budget and then decodes it
+func (b *Budget) Decode(content []byte) (image.Image, error) {
+	if _, err := b.Check(content); err != nil {
+		return nil, err
+	}
+	return Decode(content)
+}
@@ -0,0 +1,96 @@
+package card_image
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+
+	"github.com/sunshineplan/imgconv"
+)
+
+// pngHeader returns the start of a PNG declaring a width × height RGBA image,
+// enough for DecodeConfig, without the pixel data a real bomb would compress
+func pngHeader(width, height uint32) []byte {
+	ihdr := binary.BigEndian.AppendUint32([]byte("IHDR"), width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0) // 8 bit RGBA, no interlacing
+
+	b := []byte("\x89PNG\r\n\x1a\n")
+	b = binary.BigEndian.AppendUint32(b, uint32(len(ihdr)-4))
+	b = append(b, ihdr...)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestBudgetRejectsBombs(t *testing.T) {
+	bomb := pngHeader(30000, 30000)
+	if cfg, err := DecodeConfig(bomb); err != nil || cfg.Width != 30000 {
+		t.Fatalf("DecodeConfig() = %+v, %v", cfg, err)
+	}
+
+	budget := NewBudget(Limits{})
+	if _, err := budget.Decode(bomb); !errors.Is(err, ErrCardLimit) {
+		t.Errorf("Decode() error = %v, want ErrCardLimit", err)
+	}
+
+	tall := []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1 1000"/>`)
+	if _, err := budget.Decode(tall); !errors.Is(err, ErrCardLimit) {
+		t.Errorf("Decode() of a tall SVG error = %v, want ErrCardLimit", err)
+	}
+
+	small := NewBudget(Limits{MaxCardBytes: 10})
+	if _, err := small.Check(encode(t, imgconv.PNG)); !errors.Is(err, ErrCardLimit) {
+		t.Errorf("Check() error = %v, want ErrCardLimit", err)
+	}
+}
+
+func TestBudgetRequestLimits(t *testing.T) {
+	png := encode(t, imgconv.PNG) // 20 × 10
+
+	tests := []struct {
+		name   string
+		limits Limits
+	}{
+		{"pixels", Limits{MaxRequestPixels: 300}},
+		{"bytes", Limits{MaxRequestBytes: int64(len(png)) + 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := NewBudget(tt.limits)
+			if _, err := budget.Decode(png); err != nil {
+				t.Fatalf("first Decode() error = %v", err)
+			}
+			if _, err := budget.Decode(png); !errors.Is(err, ErrRequestLimit) {
+				t.Errorf("second Decode() error = %v, want ErrRequestLimit", err)
+			}
+		})
+	}
+}
+
+func TestLimitsWithDefaults(t *testing.T) {
+	got := Limits{MaxCardPixels: 100}.WithDefaults()
+	want := DefaultLimits
+	want.MaxCardPixels = 100
+	if got != want {
+		t.Errorf("WithDefaults() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLimitsCheckCanvas(t *testing.T) {
+	limits := Limits{MaxCanvasPixels: 100}
+	for _, tt := range []struct {
+		width, height int64
+		ok            bool
+	}{
+		{10, 10, true},
+		{100, 1, true},
+		{11, 10, false},
+		{101, 1, false},
+		{1 << 40, 1 << 40, false},
+	} {
+		err := limits.CheckCanvas(tt.width, tt.height)
+		if tt.ok != (err == nil) || (err != nil && !errors.Is(err, ErrCanvasLimit)) {
+			t.Errorf("CheckCanvas(%d, %d) error = %v, want ok %v", tt.width, tt.height, err, tt.ok)
+		}
+	}
+}
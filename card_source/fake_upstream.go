@@ -0,0 +1,69 @@
+package card_source
+
+import (
+	"encoding/json"
+	"main/data"
+	"net/http"
+	"sync"
+)
+
+// FakeUpstream is an in-process stand-in for the upstream benefits API. It
+// serves the ID cards registered per user and requires either an
+// X-User-Access-Token or an X-League-Auth header, like the real API.
+type FakeUpstream struct {
+	mu    sync.RWMutex
+	cards map[data.UserId][]data.IdCard
+}
+
+// NewFakeUpstream creates a fake upstream with no registered users
+func NewFakeUpstream() *FakeUpstream {
+	return &FakeUpstream{cards: map[data.UserId][]data.IdCard{}}
+}
+
+// SetIdCards registers the ID cards returned for userId
+func (f *FakeUpstream) SetIdCards(userId data.UserId, cards ...data.IdCard) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cards[userId] = cards
+}
+
+func (f *FakeUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || r.URL.Path != IdCardsPath {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get(HeaderUserAccessToken) == "" && r.Header.Get(HeaderLeagueAuth) == "" {
+		http.Error(w, "missing user access token", http.StatusUnauthorized)
+		return
+	}
+
+	params := ParamsFromRequest(r)
+	var userId data.UserId
+	if params.UserId != nil {
+		userId = *params.UserId
+	}
+
+	f.mu.RLock()
+	cards, ok := f.cards[userId]
+	f.mu.RUnlock()
+	if !ok {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	idCardsResp := data.IdCardsResponseSchema{Data: []data.IdCard{}}
+	for _, card := range cards {
+		if params.BenefitId != nil && (card.Attributes.BenefitId == nil || *card.Attributes.BenefitId != string(*params.BenefitId)) {
+			continue
+		}
+		if params.BenefitType != nil && (card.Attributes.BenefitType == nil || string(*card.Attributes.BenefitType) != string(*params.BenefitType)) {
+			continue
+		}
+		idCardsResp.Data = append(idCardsResp.Data, card)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(idCardsResp); err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,97 @@
+package card_source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"main/data"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// IdCardsPath is the path of the ID cards endpoint on the upstream benefits API
+const IdCardsPath = "/id-cards"
+
+// maxResponseBytes caps the size of an upstream response body
+const maxResponseBytes = 64 << 20
+
+// UpstreamError is returned when the upstream answers with a non-2xx status
+type UpstreamError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("upstream returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// HTTPSource fetches ID cards from an upstream benefits API over HTTP
+type HTTPSource struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewHTTPSource creates a source that calls the upstream at baseURL
+func NewHTTPSource(baseURL string) *HTTPSource {
+	return &HTTPSource{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *HTTPSource) GetIdCards(ctx context.Context, params data.GetIdCardsParams) (data.IdCardsResponseSchema, error) {
+	var idCardsResp data.IdCardsResponseSchema
+
+	query := url.Values{}
+	if params.UserId != nil {
+		query.Set("userId", string(*params.UserId))
+	}
+	if params.BenefitId != nil {
+		query.Set("benefitId", string(*params.BenefitId))
+	}
+	if params.BenefitType != nil {
+		query.Set("benefitType", string(*params.BenefitType))
+	}
+
+	reqURL := s.BaseURL + IdCardsPath
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return idCardsResp, fmt.Errorf("failed to create upstream request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	setHeader(req, HeaderUserAccessToken, params.XUserAccessToken)
+	setHeader(req, HeaderUserId, params.XUserId)
+	setHeader(req, HeaderUserIdentityToken, params.XUserIdentityToken)
+	setHeader(req, HeaderApiAccessToken, params.XApiAccessToken)
+	setHeader(req, HeaderLeagueAuth, params.XLeagueAuth)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return idCardsResp, fmt.Errorf("failed to call upstream: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body := io.LimitReader(resp.Body, maxResponseBytes)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(body, 1024))
+		return idCardsResp, &UpstreamError{StatusCode: resp.StatusCode, Body: string(msg)}
+	}
+
+	if err := json.NewDecoder(body).Decode(&idCardsResp); err != nil {
+		return idCardsResp, fmt.Errorf("failed to decode upstream response: %w", err)
+	}
+	return idCardsResp, nil
+}
+
+func setHeader[T ~string](req *http.Request, name string, value *T) {
+	if value != nil {
+		req.Header.Set(name, string(*value))
+	}
+}
@@ -0,0 +1,114 @@
+package card_source
+
+import (
+	"context"
+	"errors"
+	"main/data"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSourceAgainstFakeUpstream(t *testing.T) {
+	benefitId := "benefit-1"
+	pharmacy := data.IdCardAttributesBenefitTypePharmacy
+
+	medicalCard := data.MockIdCardFront
+	medicalCard.Attributes.BenefitId = &benefitId
+	pharmacyCard := data.MockIdCardBack
+	pharmacyCard.Attributes.BenefitType = &pharmacy
+
+	upstream := NewFakeUpstream()
+	upstream.SetIdCards("user-1", medicalCard, pharmacyCard)
+	ts := httptest.NewServer(upstream)
+	defer ts.Close()
+
+	source := NewHTTPSource(ts.URL)
+	userId := data.UserId("user-1")
+	token := data.XUserAccessToken("token")
+
+	tests := []struct {
+		name       string
+		params     data.GetIdCardsParams
+		wantIds    []string
+		wantStatus int
+	}{
+		{
+			name:    "all cards of the user",
+			params:  data.GetIdCardsParams{UserId: &userId, XUserAccessToken: &token},
+			wantIds: []string{medicalCard.Id, pharmacyCard.Id},
+		},
+		{
+			name:    "filtered by benefitId",
+			params:  data.GetIdCardsParams{UserId: &userId, BenefitId: ptr(data.BenefitId(benefitId)), XUserAccessToken: &token},
+			wantIds: []string{medicalCard.Id},
+		},
+		{
+			name:    "filtered by benefitType",
+			params:  data.GetIdCardsParams{UserId: &userId, BenefitType: ptr(data.BenefitType(pharmacy)), XUserAccessToken: &token},
+			wantIds: []string{pharmacyCard.Id},
+		},
+		{
+			name:       "missing token",
+			params:     data.GetIdCardsParams{UserId: &userId},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "unknown user",
+			params:     data.GetIdCardsParams{UserId: ptr(data.UserId("user-2")), XUserAccessToken: &token},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := source.GetIdCards(context.Background(), tt.params)
+			if tt.wantStatus != 0 {
+				var upstreamErr *UpstreamError
+				if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != tt.wantStatus {
+					t.Fatalf("GetIdCards() error = %v, want status %d", err, tt.wantStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetIdCards() error = %v", err)
+			}
+
+			var gotIds []string
+			for _, card := range got.Data {
+				gotIds = append(gotIds, card.Id)
+			}
+			if len(gotIds) != len(tt.wantIds) {
+				t.Fatalf("GetIdCards() ids = %v, want %v", gotIds, tt.wantIds)
+			}
+			for i := range gotIds {
+				if gotIds[i] != tt.wantIds[i] {
+					t.Errorf("GetIdCards() ids = %v, want %v", gotIds, tt.wantIds)
+				}
+			}
+		})
+	}
+}
+
+func TestParamsFromRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/pdf/idcards?userId=u1&benefitId=b1", nil)
+	req.Header.Set(HeaderLeagueAuth, "league-token")
+
+	params := ParamsFromRequest(req)
+	if params.UserId == nil || *params.UserId != "u1" {
+		t.Errorf("UserId = %v", params.UserId)
+	}
+	if params.BenefitId == nil || *params.BenefitId != "b1" {
+		t.Errorf("BenefitId = %v", params.BenefitId)
+	}
+	if params.BenefitType != nil {
+		t.Errorf("BenefitType = %v, want nil", *params.BenefitType)
+	}
+	if params.XLeagueAuth == nil || *params.XLeagueAuth != "league-token" {
+		t.Errorf("XLeagueAuth = %v", params.XLeagueAuth)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,76 @@
+package card_source
+
+import (
+	"context"
+	"main/data"
+	"net/http"
+)
+
+// Header names forwarded from the incoming request to the upstream benefits API
+const (
+	HeaderUserAccessToken   = "X-User-Access-Token"
+	HeaderUserId            = "X-User-Id"
+	HeaderUserIdentityToken = "X-User-Identity-Token"
+	HeaderApiAccessToken    = "X-Api-Access-Token"
+	HeaderLeagueAuth        = "X-League-Auth"
+)
+
+// IdCardsSource provides the ID cards of a member
+type IdCardsSource interface {
+	GetIdCards(ctx context.Context, params data.GetIdCardsParams) (data.IdCardsResponseSchema, error)
+}
+
+// StaticSource always returns the same ID cards regardless of the params
+type StaticSource struct {
+	IdCardsResp data.IdCardsResponseSchema
+}
+
+// NewStaticSource creates a source that serves the given ID cards
+func NewStaticSource(idCardsResp data.IdCardsResponseSchema) *StaticSource {
+	return &StaticSource{IdCardsResp: idCardsResp}
+}
+
+func (s *StaticSource) GetIdCards(_ context.Context, _ data.GetIdCardsParams) (data.IdCardsResponseSchema, error) {
+	return s.IdCardsResp, nil
+}
+
+// ParamsFromRequest extracts the GetIdCards parameters from the query string and
+// headers of an incoming request
+func ParamsFromRequest(r *http.Request) data.GetIdCardsParams {
+	query := r.URL.Query()
+
+	var params data.GetIdCardsParams
+	if v := query.Get("userId"); v != "" {
+		userId := data.UserId(v)
+		params.UserId = &userId
+	}
+	if v := query.Get("benefitId"); v != "" {
+		benefitId := data.BenefitId(v)
+		params.BenefitId = &benefitId
+	}
+	if v := query.Get("benefitType"); v != "" {
+		benefitType := data.BenefitType(v)
+		params.BenefitType = &benefitType
+	}
+	if v := r.Header.Get(HeaderUserAccessToken); v != "" {
+		token := data.XUserAccessToken(v)
+		params.XUserAccessToken = &token
+	}
+	if v := r.Header.Get(HeaderUserId); v != "" {
+		userId := data.XUserId(v)
+		params.XUserId = &userId
+	}
+	if v := r.Header.Get(HeaderUserIdentityToken); v != "" {
+		token := data.XUserIdentityToken(v)
+		params.XUserIdentityToken = &token
+	}
+	if v := r.Header.Get(HeaderApiAccessToken); v != "" {
+		token := data.XApiAccessToken(v)
+		params.XApiAccessToken = &token
+	}
+	if v := r.Header.Get(HeaderLeagueAuth); v != "" {
+		token := data.XLeagueAuth(v)
+		params.XLeagueAuth = &token
+	}
+	return params
+}
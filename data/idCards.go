@@ -112,7 +112,7 @@ var MockHTMLIdCardBoth = IdCard{
 		BenefitId:   nil,
 		BenefitType: nil,
 		Type:        IdCardAttributesTypeHTML,
-		Face:        "combined",
+		Face:        IdCardAttributesFaceCombined,
 		Source:      htmlIdCardBoth,
 	},
 }
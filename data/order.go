@@ -0,0 +1,118 @@
+package data
+
+import (
+	"sort"
+	"strings"
+)
+
+// IdCardAttributesFaceCombined is the face of a card whose source shows both
+// sides at once, like MockHTMLIdCardBoth
+const IdCardAttributesFaceCombined IdCardAttributesFace = "combined"
+
+// idCardFaceSuffixes are stripped from card ids to find the card they belong to
+var idCardFaceSuffixes = []string{"-front", "-back", "-both", "-combined"}
+
+// BenefitKey returns the key grouping the faces of the same physical card. It
+// is the benefit id when present and otherwise the card id without its face
+// suffix.
+func BenefitKey(card IdCard) string {
+	if card.Attributes.BenefitId != nil && *card.Attributes.BenefitId != "" {
+		return *card.Attributes.BenefitId
+	}
+	for _, suffix := range idCardFaceSuffixes {
+		if strings.HasSuffix(card.Id, suffix) {
+			return strings.TrimSuffix(card.Id, suffix)
+		}
+	}
+	return card.Id
+}
+
+// faceRank orders the faces of a card: front, back, then anything else
+func faceRank(face IdCardAttributesFace) int {
+	switch face {
+	case IdCardAttributesFaceFront:
+		return 0
+	case IdCardAttributesFaceBack:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// SortIdCardsByBenefit returns a copy of cards grouped by benefit, with groups
+// kept in order of first appearance and each group ordered front then back
+func SortIdCardsByBenefit(cards []IdCard) []IdCard {
+	groupOrder := map[string]int{}
+	for _, card := range cards {
+		key := BenefitKey(card)
+		if _, ok := groupOrder[key]; !ok {
+			groupOrder[key] = len(groupOrder)
+		}
+	}
+
+	sorted := make([]IdCard, len(cards))
+	copy(sorted, cards)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		gi, gj := groupOrder[BenefitKey(sorted[i])], groupOrder[BenefitKey(sorted[j])]
+		if gi != gj {
+			return gi < gj
+		}
+		return faceRank(sorted[i].Attributes.Face) < faceRank(sorted[j].Attributes.Face)
+	})
+	return sorted
+}
+
+// IdCardPair is one printed row of a card: its front and back faces side by
+// side, or a single face that already shows both sides. Fields are indexes into
+// the slice given to PairIdCards and are -1 when the face is absent.
+type IdCardPair struct {
+	Front    int
+	Back     int
+	Combined int
+}
+
+// PairIdCards groups cards by BenefitKey and pairs each front with a back of the
+// same card. Combined faces and faces of any other kind form a row of their
+// own. Rows keep the order in which their first card appears.
+func PairIdCards(cards []IdCard) []IdCardPair {
+	var pairs []IdCardPair
+	// Rows per benefit that still have a free front or back slot
+	open := map[string][]int{}
+
+	for i, card := range cards {
+		face := card.Attributes.Face
+		if face != IdCardAttributesFaceFront && face != IdCardAttributesFaceBack {
+			pairs = append(pairs, IdCardPair{Front: -1, Back: -1, Combined: i})
+			continue
+		}
+
+		key := BenefitKey(card)
+		paired := false
+		for n, row := range open[key] {
+			pair := &pairs[row]
+			if face == IdCardAttributesFaceFront && pair.Front == -1 {
+				pair.Front = i
+			} else if face == IdCardAttributesFaceBack && pair.Back == -1 {
+				pair.Back = i
+			} else {
+				continue
+			}
+			open[key] = append(open[key][:n], open[key][n+1:]...)
+			paired = true
+			break
+		}
+		if paired {
+			continue
+		}
+
+		pair := IdCardPair{Front: -1, Back: -1, Combined: -1}
+		if face == IdCardAttributesFaceFront {
+			pair.Front = i
+		} else {
+			pair.Back = i
+		}
+		open[key] = append(open[key], len(pairs))
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
@@ -0,0 +1,86 @@
+package data
+
+import "testing"
+
+func TestSortIdCardsByBenefit(t *testing.T) {
+	cards := []IdCard{
+		MockIdCardBack,
+		MockHTMLIdCardBoth,
+		MockImageIdCardBack,
+		MockIdCardFront,
+		MockImageIdCardFront,
+	}
+
+	got := SortIdCardsByBenefit(cards)
+
+	want := []string{
+		MockIdCardFront.Id,
+		MockIdCardBack.Id,
+		MockHTMLIdCardBoth.Id,
+		MockImageIdCardFront.Id,
+		MockImageIdCardBack.Id,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("SortIdCardsByBenefit() returned %d cards, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Id != want[i] {
+			t.Errorf("SortIdCardsByBenefit()[%d] = %s, want %s", i, got[i].Id, want[i])
+		}
+	}
+
+	if cards[0].Id != MockIdCardBack.Id {
+		t.Error("SortIdCardsByBenefit() modified its input")
+	}
+}
+
+func TestBenefitKey(t *testing.T) {
+	benefitId := "benefit-1"
+	card := MockIdCardFront
+	card.Attributes.BenefitId = &benefitId
+
+	tests := []struct {
+		name string
+		card IdCard
+		want string
+	}{
+		{name: "benefit id", card: card, want: "benefit-1"},
+		{name: "front id suffix", card: MockIdCardFront, want: "mock-id-card"},
+		{name: "back id suffix", card: MockIdCardBack, want: "mock-id-card"},
+		{name: "both id suffix", card: MockHTMLIdCardBoth, want: "mock-html-id-card"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BenefitKey(tt.card); got != tt.want {
+				t.Errorf("BenefitKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPairIdCards(t *testing.T) {
+	cards := []IdCard{
+		MockIdCardBack,
+		MockImageIdCardFront,
+		MockHTMLIdCardBoth,
+		MockIdCardFront,
+		MockHTMLIdCardFront,
+	}
+
+	got := PairIdCards(cards)
+
+	want := []IdCardPair{
+		{Front: 3, Back: 0, Combined: -1},
+		{Front: 1, Back: -1, Combined: -1},
+		{Front: -1, Back: -1, Combined: 2},
+		{Front: 4, Back: -1, Combined: -1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("PairIdCards() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("PairIdCards()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Errors returned by Fetch when a request is refused
+var (
+	ErrSchemeNotAllowed = errors.New("URL scheme not allowed")
+	ErrHostNotAllowed   = errors.New("host not allowed")
+	ErrBlockedAddress   = errors.New("address is private, loopback or link-local")
+	ErrTooLarge         = errors.New("response body too large")
+	ErrContentType      = errors.New("content type not allowed")
+	ErrStatus           = errors.New("unexpected response status")
+)
+
+// Config controls what a Fetcher is allowed to download
+type Config struct {
+	// ConnectTimeout bounds establishing the TCP connection
+	ConnectTimeout time.Duration
+	// ReadTimeout bounds the whole request, from sending it to reading the body
+	ReadTimeout time.Duration
+	// MaxBytes caps the size of a response body
+	MaxBytes int64
+	// AllowedHosts restricts fetches to these hosts when not empty. An entry
+	// starting with a dot, like ".ctfassets.net", matches every subdomain.
+	AllowedHosts []string
+	// AllowedContentTypes lists accepted media types; an entry ending in "/*"
+	// matches a whole family. Defaults to image/*.
+	AllowedContentTypes []string
+	// AllowPrivateNetworks permits loopback, RFC1918 and link-local addresses.
+	// Only meant for tests against local servers.
+	AllowPrivateNetworks bool
+	// MaxRedirects caps how many redirects are followed
+	MaxRedirects int
+}
+
+// DefaultConfig is the configuration of the default fetcher
+var DefaultConfig = Config{
+	ConnectTimeout:      5 * time.Second,
+	ReadTimeout:         15 * time.Second,
+	MaxBytes:            10 << 20,
+	AllowedContentTypes: []string{"image/*"},
+	MaxRedirects:        3,
+}
+
+// Fetcher downloads remote resources with timeouts, size caps and protection
+// against server-side request forgery
+type Fetcher struct {
+	cfg    Config
+	client *http.Client
+}
+
+// New creates a Fetcher from cfg, filling unset fields from DefaultConfig
+func New(cfg Config) *Fetcher {
+	if cfg.ConnectTimeout <= 0 {
+		cfg.ConnectTimeout = DefaultConfig.ConnectTimeout
+	}
+	if cfg.ReadTimeout <= 0 {
+		cfg.ReadTimeout = DefaultConfig.ReadTimeout
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultConfig.MaxBytes
+	}
+	if len(cfg.AllowedContentTypes) == 0 {
+		cfg.AllowedContentTypes = DefaultConfig.AllowedContentTypes
+	}
+	if cfg.MaxRedirects <= 0 {
+		cfg.MaxRedirects = DefaultConfig.MaxRedirects
+	}
+
+	f := &Fetcher{cfg: cfg}
+	dialer := &net.Dialer{
+		Timeout: cfg.ConnectTimeout,
+		// Check the resolved address right before connecting so DNS answers
+		// cannot point an allowed name at an internal address
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return f.checkIP(net.ParseIP(host))
+		},
+	}
+	f.client = &http.Client{
+		Timeout: cfg.ReadTimeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   cfg.ConnectTimeout,
+			ResponseHeaderTimeout: cfg.ReadTimeout,
+			MaxIdleConns:          20,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > cfg.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", cfg.MaxRedirects)
+			}
+			return f.checkURL(req.URL)
+		},
+	}
+	return f
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultFetcher = New(DefaultConfig)
+)
+
+// Default returns the fetcher shared by the packages that download card images
+func Default() *Fetcher {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultFetcher
+}
+
+// SetDefault replaces the shared fetcher
+func SetDefault(f *Fetcher) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultFetcher = f
+}
+
+// Result is a downloaded resource
+type Result struct {
+	Body        []byte
+	ContentType string
+}
+
+// Fetch downloads rawURL after checking it against the fetcher's policy
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Result, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if err := f.checkURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%w: %s", ErrStatus, resp.Status)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if err := f.checkContentType(contentType); err != nil {
+		return nil, err
+	}
+
+	if resp.ContentLength > f.cfg.MaxBytes {
+		return nil, fmt.Errorf("%w: %d bytes exceeds %d", ErrTooLarge, resp.ContentLength, f.cfg.MaxBytes)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.cfg.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if int64(len(body)) > f.cfg.MaxBytes {
+		return nil, fmt.Errorf("%w: exceeds %d bytes", ErrTooLarge, f.cfg.MaxBytes)
+	}
+
+	return &Result{Body: body, ContentType: contentType}, nil
+}
+
+func (f *Fetcher) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: %q", ErrSchemeNotAllowed, u.Scheme)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if !f.hostAllowed(host) {
+		return fmt.Errorf("%w: %s", ErrHostNotAllowed, host)
+	}
+	// Literal IPs are checked up front; names are checked once resolved
+	if ip := net.ParseIP(host); ip != nil {
+		return f.checkIP(ip)
+	}
+	return nil
+}
+
+// ParseHosts splits a comma separated host list, as given on the command
+// line, into AllowedHosts entries. Spaces around entries and empty entries are
+// dropped.
+func ParseHosts(list string) []string {
+	var hosts []string
+	for _, host := range strings.Split(list, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+func (f *Fetcher) hostAllowed(host string) bool {
+	if len(f.cfg.AllowedHosts) == 0 {
+		return true
+	}
+	for _, allowed := range f.cfg.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Fetcher) checkIP(ip net.IP) error {
+	if ip == nil {
+		return fmt.Errorf("%w: unparsable address", ErrBlockedAddress)
+	}
+	if f.cfg.AllowPrivateNetworks {
+		return nil
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, ip)
+	}
+	return nil
+}
+
+func (f *Fetcher) checkContentType(contentType string) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrContentType, contentType)
+	}
+	for _, allowed := range f.cfg.AllowedContentTypes {
+		if mediaType == allowed ||
+			(strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*"))) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrContentType, mediaType)
+}
@@ -0,0 +1,115 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newImageServer(contentType string, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		default:
+			w.Header().Set("Content-Type", contentType)
+			w.Write([]byte(body))
+		}
+	}))
+}
+
+func TestFetch(t *testing.T) {
+	ts := newImageServer("image/png", "png-bytes")
+	defer ts.Close()
+	html := newImageServer("text/html; charset=utf-8", "<html></html>")
+	defer html.Close()
+
+	local := Config{AllowPrivateNetworks: true}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		url     string
+		wantErr error
+	}{
+		{name: "allowed", cfg: local, url: ts.URL + "/card.png"},
+		{name: "loopback blocked by default", cfg: Config{}, url: ts.URL + "/card.png", wantErr: ErrBlockedAddress},
+		{name: "metadata address blocked", cfg: Config{}, url: "http://169.254.169.254/latest/meta-data", wantErr: ErrBlockedAddress},
+		{name: "private address blocked", cfg: Config{}, url: "http://10.0.0.1/card.png", wantErr: ErrBlockedAddress},
+		{name: "scheme not allowed", cfg: local, url: "file:///etc/passwd", wantErr: ErrSchemeNotAllowed},
+		{name: "host not in allowlist", cfg: Config{AllowPrivateNetworks: true, AllowedHosts: []string{".ctfassets.net"}}, url: ts.URL + "/card.png", wantErr: ErrHostNotAllowed},
+		{name: "content type", cfg: local, url: html.URL + "/page", wantErr: ErrContentType},
+		{name: "too large", cfg: Config{AllowPrivateNetworks: true, MaxBytes: 4}, url: ts.URL + "/card.png", wantErr: ErrTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.cfg).Fetch(context.Background(), tt.url)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Fetch() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if string(got.Body) != "png-bytes" || got.ContentType != "image/png" {
+				t.Errorf("Fetch() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestFetchRedirectToBlockedAddress(t *testing.T) {
+	ts := newImageServer("image/png", "png-bytes")
+	defer ts.Close()
+
+	// The first hop is allowed, the redirect target is checked again
+	f := New(Config{AllowPrivateNetworks: true, AllowedHosts: []string{"127.0.0.1"}})
+	_, err := f.Fetch(context.Background(), ts.URL+"/redirect")
+	if !errors.Is(err, ErrHostNotAllowed) {
+		t.Fatalf("Fetch() error = %v, want %v", err, ErrHostNotAllowed)
+	}
+}
+
+func TestFetchReadTimeout(t *testing.T) {
+	ts := newImageServer("image/png", "png-bytes")
+	defer ts.Close()
+
+	f := New(Config{AllowPrivateNetworks: true, ReadTimeout: 50 * time.Millisecond})
+	start := time.Now()
+	_, err := f.Fetch(context.Background(), ts.URL+"/slow")
+	if err == nil || !strings.Contains(err.Error(), "Client.Timeout") {
+		t.Fatalf("Fetch() error = %v, want client timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Fetch() took %v", elapsed)
+	}
+}
+
+func TestParseHosts(t *testing.T) {
+	got := ParseHosts(" cdn.example.com, .ctfassets.net ,,")
+	want := []string{"cdn.example.com", ".ctfassets.net"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseHosts() = %q, want %q", got, want)
+	}
+	if got := ParseHosts(" , "); len(got) != 0 {
+		t.Errorf("ParseHosts() of blanks = %q, want none", got)
+	}
+
+	f := New(Config{AllowedHosts: ParseHosts("a.com, b.com")})
+	if !f.hostAllowed("b.com") {
+		t.Error("b.com is not allowed after a space in the list")
+	}
+}
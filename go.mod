@@ -3,18 +3,25 @@ module main
 go 1.24
 
 require (
+	github.com/HugoSmits86/nativewebp v1.1.0
 	github.com/SebastiaanKlippert/go-wkhtmltopdf v1.9.3
 	github.com/chromedp/cdproto v0.0.0-20250311215558-29dfcc2791de
 	github.com/chromedp/chromedp v0.13.1
 	github.com/disintegration/imaging v1.6.2
 	github.com/gen2brain/go-fitz v1.24.14
 	github.com/go-chi/chi/v5 v5.2.1
+	github.com/pdfcpu/pdfcpu v0.5.0
+	github.com/srwiley/oksvg v0.0.0-20221011165216-be6e8873101c
+	github.com/srwiley/rasterx v0.0.0-20220730225603-2ab79fcdd4ef
 	github.com/sunshineplan/imgconv v1.1.14
+	github.com/unidoc/pkcs7 v0.2.0
+	github.com/unidoc/timestamp v0.0.0-20200412005513-91597fd3793a
 	github.com/unidoc/unipdf/v3 v3.67.0
+	golang.org/x/image v0.25.0
+	golang.org/x/net v0.34.0
 )
 
 require (
-	github.com/HugoSmits86/nativewebp v1.1.0 // indirect
 	github.com/adrg/strutil v0.3.1 // indirect
 	github.com/adrg/sysfont v0.1.2 // indirect
 	github.com/adrg/xdg v0.5.3 // indirect
@@ -31,7 +38,6 @@ require (
 	github.com/hhrutter/tiff v1.0.1 // indirect
 	github.com/jupiterrider/ffi v0.2.0 // indirect
 	github.com/mattn/go-runewidth v0.0.15 // indirect
-	github.com/pdfcpu/pdfcpu v0.5.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/rivo/uniseg v0.4.4 // indirect
@@ -39,13 +45,9 @@ require (
 	github.com/stretchr/testify v1.10.0 // indirect
 	github.com/sunshineplan/pdf v1.0.7 // indirect
 	github.com/unidoc/freetype v0.2.3 // indirect
-	github.com/unidoc/pkcs7 v0.2.0 // indirect
-	github.com/unidoc/timestamp v0.0.0-20200412005513-91597fd3793a // indirect
 	github.com/unidoc/unichart v0.3.0 // indirect
 	github.com/unidoc/unitype v0.5.1 // indirect
 	golang.org/x/crypto v0.32.0 // indirect
-	golang.org/x/image v0.25.0 // indirect
-	golang.org/x/net v0.34.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/text v0.23.0 // indirect
 	golang.org/x/xerrors v0.0.0-20240903120638-7835f813f4da // indirect
@@ -1,111 +1,127 @@
 package main
 
 import (
-	"bytes"
-	"context"
-	"fmt"
-	"github.com/go-chi/chi/v5"
-	"io"
+	"flag"
 	"log"
-	"main/data"
+	"main/browser_pool"
+	"main/card_image"
+	"main/card_source"
+	"main/fetcher"
+	"main/server"
 	"main/to_image"
 	"main/to_pdf"
-	"net/http"
 )
 
-func main() {
-	addr := ":8081"
-	if err := StartServer(addr); err != nil {
-		log.Fatalf("Server error: %v", err)
-	}
-}
+// defaultChromePoolSize is the pool size used when the default PDF renderer or
+// HTML rasterizer runs on Chrome and -chrome-pool-size is not set
+const defaultChromePoolSize = 4
 
-// Server represents the PDF HTTP server
-type Server struct {
-	router      chi.Router
-	idCardsResp data.IdCardsResponseSchema // Store ID cards data
-}
+var (
+	pdfRendererName  = flag.String("pdf-renderer", to_pdf.RendererChromedp, "Default PDF renderer (chromedp, or wkhtmltopdf for untagged PDFs)")
+	upstreamURL      = flag.String("upstream-url", "", "Base URL of the upstream benefits API (mock ID cards are served when empty)")
+	requestTimeout   = flag.Duration("request-timeout", server.DefaultRequestTimeout, "Maximum time spent on a single request")
+	htmlRasterizer   = flag.String("html-rasterizer", to_image.RasterizerScreenshot, "Engine rendering HTML cards to images (screenshot or pdf)")
+	chromePoolSize   = flag.Int("chrome-pool-size", defaultChromePoolSize, "Number of tabs in a shared headless Chrome used by chromedp rendering and HTML card screenshots (a Chrome is started per render when 0)")
+	themeDir         = flag.String("theme-dir", "", "Directory with one subdirectory of PDF templates per tenant theme")
+	imageHosts       = flag.String("allowed-image-hosts", "", "Comma separated hosts remote card images may be fetched from; a leading dot matches subdomains (any public host when empty)")
+	signingCert      = flag.String("signing-cert", "", "PEM file with the issuer certificate followed by its chain, used to sign PDFs")
+	signingKey       = flag.String("signing-key", "", "PEM file with the private key of the signing certificate")
+	tsaURL           = flag.String("tsa-url", "", "RFC 3161 time stamping authority for PDF signatures (signatures are not timestamped when empty)")
+	veraPDFPath      = flag.String("verapdf", "", "veraPDF executable that validates every archival PDF before it is returned (only built-in checks when empty)")
+	maxCardBytes     = flag.Int64("max-card-bytes", card_image.DefaultLimits.MaxCardBytes, "Maximum encoded size of a card image in bytes")
+	maxCardPixels    = flag.Int64("max-card-pixels", card_image.DefaultLimits.MaxCardPixels, "Maximum width × height of a decoded card image")
+	maxRequestBytes  = flag.Int64("max-request-bytes", card_image.DefaultLimits.MaxRequestBytes, "Maximum encoded size of all card images of a request in bytes")
+	maxRequestPixels = flag.Int64("max-request-pixels", card_image.DefaultLimits.MaxRequestPixels, "Maximum width × height of all decoded card images of a request")
+	maxCanvasPixels  = flag.Int64("max-canvas-pixels", card_image.DefaultLimits.MaxCanvasPixels, "Maximum width × height of a merged ID card image")
+)
 
-// NewServer creates a new PDF server
-func NewServer() *Server {
-	s := &Server{
-		router: chi.NewRouter(),
-		idCardsResp: data.IdCardsResponseSchema{ // Initialize ID cards data
-			Data: []data.IdCard{
-				data.MockImageIdCardFront,
-				data.MockImageIdCardBack,
-				data.MockIdCardFront,
-				data.MockIdCardBack,
-				data.MockHTMLIdCardFront,
-				data.MockHTMLIdCardBack,
-				data.MockHTMLIdCardBoth,
-			},
-		},
+func main() {
+	flag.Parse()
+
+	// Without Chrome in use a pool would only keep an idle browser running
+	usesChrome := *pdfRendererName == to_pdf.RendererChromedp || *htmlRasterizer == to_image.RasterizerScreenshot
+	if !usesChrome && !flagSet("chrome-pool-size") {
+		*chromePoolSize = 0
 	}
-	s.routes()
-	return s
-}
 
-// routes sets up all the routes for the PDF server
-func (s *Server) routes() {
-	s.router.Get("/pdf/idcards", s.handleGetIDCardsPDF())
-	s.router.Get("/image/idcards", s.handleGetIDCardsImage())
-	s.router.Get("/template-extension/idcards", s.handleGetIDCardsTemplateExtension())
-}
+	var pool *browser_pool.Pool
+	if *chromePoolSize > 0 {
+		pool = browser_pool.New(browser_pool.Config{Size: *chromePoolSize})
+		defer pool.Close()
+		chromedpRenderer := to_pdf.NewChromedpRenderer()
+		chromedpRenderer.Pool = pool
+		to_pdf.RegisterRenderer(chromedpRenderer)
+	}
 
-// ServeHTTP implements the http.Handler interface
-func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.router.ServeHTTP(w, r)
-}
+	if *themeDir != "" {
+		names, err := to_pdf.LoadThemes(*themeDir)
+		if err != nil {
+			log.Fatalf("Invalid theme directory: %v", err)
+		}
+		log.Printf("Loaded PDF themes: %v", names)
+	}
 
-// writeResponse is a helper function to write content to response with proper error handling
-func writeResponse(w http.ResponseWriter, content []byte, fileName string, contentType string) {
-	w.Header().Set("Content-Disposition", "attachment; filename="+fileName)
-	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+	renderer, err := to_pdf.GetRenderer(*pdfRendererName)
+	if err != nil {
+		log.Fatalf("Invalid PDF renderer: %v", err)
+	}
+	if !to_pdf.Tagged(renderer) {
+		log.Printf("Warning: the %s renderer writes untagged PDFs that screen readers cannot navigate", renderer.Name())
+	}
 
-	if _, err := io.Copy(w, bytes.NewReader(content)); err != nil {
-		log.Printf("Error writing response: %v", err)
-		// We can't change the status code at this point as headers are already sent
+	rasterizer, err := to_image.GetHTMLRasterizer(*htmlRasterizer)
+	if err != nil {
+		log.Fatalf("Invalid HTML rasterizer: %v", err)
+	}
+	if _, ok := rasterizer.(to_image.ScreenshotRasterizer); ok && pool != nil {
+		rasterizer = to_image.ScreenshotRasterizer{Pool: pool}
 	}
-}
 
-func (s *Server) handleGetIDCardsTemplateExtension() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Implementation not provided
+	if hosts := fetcher.ParseHosts(*imageHosts); len(hosts) > 0 {
+		cfg := fetcher.DefaultConfig
+		cfg.AllowedHosts = hosts
+		fetcher.SetDefault(fetcher.New(cfg))
 	}
-}
 
-func (s *Server) handleGetIDCardsImage() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Generate the merged image
-		response, err := to_image.MergeImages(context.Background(), s.idCardsResp)
+	opts := []server.ServerOption{server.WithPDFRenderer(renderer), server.WithHTMLRasterizer(rasterizer), server.WithRequestTimeout(*requestTimeout)}
+	opts = append(opts, server.WithImageLimits(card_image.Limits{
+		MaxCardBytes:     *maxCardBytes,
+		MaxCardPixels:    *maxCardPixels,
+		MaxRequestBytes:  *maxRequestBytes,
+		MaxRequestPixels: *maxRequestPixels,
+		MaxCanvasPixels:  *maxCanvasPixels,
+	}))
+	if *upstreamURL != "" {
+		opts = append(opts, server.WithIdCardsSource(card_source.NewHTTPSource(*upstreamURL)))
+	}
+	if *signingCert != "" || *signingKey != "" {
+		signer, err := to_pdf.LoadSigner(*signingCert, *signingKey)
 		if err != nil {
-			http.Error(w, "Failed to generate image", http.StatusInternalServerError)
-			return
+			log.Fatalf("Invalid signing certificate: %v", err)
 		}
-
-		writeResponse(w, response.ImageContent, response.FileName, "image/png")
+		signer.TSAURL = *tsaURL
+		opts = append(opts, server.WithSigner(signer))
 	}
-}
 
-// handleGetIDCardsPDF returns a handler function for generating PDF from ID cards
-func (s *Server) handleGetIDCardsPDF() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Generate the PDF
-		response, err := to_pdf.GeneratePDFFromIDCards(context.Background(), s.idCardsResp)
+	if *veraPDFPath != "" {
+		validator, err := to_pdf.NewVeraPDF(*veraPDFPath)
 		if err != nil {
-			http.Error(w, "Failed to generate PDF", http.StatusInternalServerError)
-			return
+			log.Fatalf("Invalid veraPDF: %v", err)
 		}
+		opts = append(opts, server.WithPDFAValidator(validator))
+	}
 
-		writeResponse(w, response.PDFContent, response.FileName, "application/pdf")
+	addr := ":8081"
+	if err := server.StartServer(addr, opts...); err != nil {
+		log.Fatalf("Server error: %v", err)
 	}
 }
 
-// StartServer starts the PDF server on the specified address
-func StartServer(addr string) error {
-	server := NewServer()
-	fmt.Printf("Starting PDF server on %s\n", addr)
-	return http.ListenAndServe(addr, server)
+// flagSet reports whether the named flag was given on the command line
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
 }
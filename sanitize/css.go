@@ -0,0 +1,180 @@
+package sanitize
+
+import (
+	"strings"
+)
+
+// unsafeCSS are value fragments that run code or hide what a value loads
+var unsafeCSS = []string{"\\", "<", "{", "}", "@import", "expression(", "javascript:", "behavior", "-moz-binding", "image-set("}
+
+// Stylesheet sanitises the contents of a <style> element. Rules keep only the
+// allowed declarations and at-rules such as @import, @font-face and @media
+// are dropped.
+func (p *Policy) Stylesheet(css string) string {
+	// A stray "<" could close the <style> element
+	css = strings.ReplaceAll(stripComments(css), "<", "")
+
+	var sb strings.Builder
+	for rest := css; ; {
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			break
+		}
+
+		if rest[0] == '@' {
+			end := indexTopLevel(rest, ";{")
+			if end == -1 {
+				break
+			}
+			if rest[end] == ';' {
+				rest = rest[end+1:]
+				continue
+			}
+			blockEnd := matchingBrace(rest, end)
+			if blockEnd == -1 {
+				break
+			}
+			rest = rest[blockEnd+1:]
+			continue
+		}
+
+		blockStart := indexTopLevel(rest, "{")
+		if blockStart == -1 {
+			break
+		}
+		blockEnd := matchingBrace(rest, blockStart)
+		if blockEnd == -1 {
+			break
+		}
+		selector := strings.TrimSpace(rest[:blockStart])
+		declarations := p.Declarations(rest[blockStart+1 : blockEnd])
+		rest = rest[blockEnd+1:]
+		if selector == "" || strings.ContainsAny(selector, "};") || declarations == "" {
+			continue
+		}
+		sb.WriteString(selector)
+		sb.WriteString("{")
+		sb.WriteString(declarations)
+		sb.WriteString("}")
+	}
+	return sb.String()
+}
+
+// Declarations sanitises a declaration list, as found in a style attribute.
+// Declarations of properties outside the policy and values that could run
+// code or load anything but a data:image URI are dropped.
+func (p *Policy) Declarations(css string) string {
+	var kept []string
+	for _, decl := range splitTopLevel(stripComments(css), ';') {
+		prop, value, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		prop = strings.ToLower(strings.TrimSpace(prop))
+		value = strings.TrimSpace(value)
+		if !p.CSSProperties[prop] || value == "" || !safeValue(value) {
+			continue
+		}
+		kept = append(kept, prop+":"+value)
+	}
+	return strings.Join(kept, ";")
+}
+
+// safeValue reports whether a declaration value is free of code and remote
+// resources
+func safeValue(value string) bool {
+	lower := strings.ToLower(value)
+	for _, s := range unsafeCSS {
+		if strings.Contains(lower, s) {
+			return false
+		}
+	}
+	for rest := lower; ; {
+		i := strings.Index(rest, "url(")
+		if i == -1 {
+			return true
+		}
+		rest = rest[i+len("url("):]
+		arg := strings.TrimLeft(rest, " \t\n\"'")
+		if !strings.HasPrefix(arg, "data:image/") {
+			return false
+		}
+	}
+}
+
+// stripComments removes /* */ comments
+func stripComments(css string) string {
+	var sb strings.Builder
+	for {
+		start := strings.Index(css, "/*")
+		if start == -1 {
+			sb.WriteString(css)
+			return sb.String()
+		}
+		sb.WriteString(css[:start])
+		end := strings.Index(css[start+2:], "*/")
+		if end == -1 {
+			return sb.String()
+		}
+		css = css[start+2+end+2:]
+	}
+}
+
+// indexTopLevel returns the index of the first of chars in css that is not
+// inside a string or parentheses, or -1
+func indexTopLevel(css string, chars string) int {
+	var quote byte
+	depth := 0
+	for i := 0; i < len(css); i++ {
+		c := css[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+		case depth == 0 && strings.IndexByte(chars, c) != -1:
+			return i
+		}
+	}
+	return -1
+}
+
+// matchingBrace returns the index of the "}" closing the "{" at open, or -1
+func matchingBrace(css string, open int) int {
+	depth := 0
+	for i := open; i < len(css); {
+		j := indexTopLevel(css[i:], "{}")
+		if j == -1 {
+			return -1
+		}
+		i += j
+		if css[i] == '{' {
+			depth++
+		} else if depth--; depth == 0 {
+			return i
+		}
+		i++
+	}
+	return -1
+}
+
+// splitTopLevel splits css on sep outside strings and parentheses
+func splitTopLevel(css string, sep byte) []string {
+	var parts []string
+	for {
+		i := indexTopLevel(css, string(sep))
+		if i == -1 {
+			return append(parts, css)
+		}
+		parts = append(parts, css[:i])
+		css = css[i+1:]
+	}
+}
@@ -0,0 +1,209 @@
+package sanitize
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Policy lists the markup an HTML card may use. Anything not listed is
+// removed: disallowed elements are unwrapped so their text survives, except
+// for those in DropContent which go away together with their content.
+type Policy struct {
+	// Elements are the allowed tag names
+	Elements map[string]bool
+	// DropContent are the tag names removed together with everything inside
+	DropContent map[string]bool
+	// GlobalAttributes are allowed on every allowed element. A trailing "*"
+	// matches any suffix, as in "aria-*".
+	GlobalAttributes []string
+	// Attributes are allowed on specific elements, keyed by tag name
+	Attributes map[string][]string
+	// CSSProperties are the properties kept in style attributes and <style>
+	// elements
+	CSSProperties map[string]bool
+}
+
+// DefaultPolicy allows static, self-contained card markup: text, layout
+// elements, inline styles and images embedded as data URIs
+var DefaultPolicy = &Policy{
+	Elements: set(
+		"a", "abbr", "b", "br", "caption", "code", "col", "colgroup", "dd", "del", "div", "dl", "dt",
+		"em", "figcaption", "figure", "footer", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hr",
+		"i", "img", "ins", "li", "mark", "ol", "p", "pre", "s", "section", "small", "span", "strong",
+		"style", "sub", "sup", "table", "tbody", "td", "tfoot", "th", "thead", "tr", "u", "ul",
+	),
+	DropContent: set(
+		"applet", "audio", "base", "button", "canvas", "embed", "form", "frame", "frameset", "head",
+		"iframe", "input", "link", "math", "meta", "noembed", "noframes", "noscript", "object",
+		"picture", "script", "select", "source", "svg", "template", "textarea", "title", "track",
+		"video",
+	),
+	GlobalAttributes: []string{"aria-*", "class", "dir", "id", "lang", "role", "style", "title"},
+	Attributes: map[string][]string{
+		"img": {"alt", "height", "src", "width"},
+		"td":  {"colspan", "rowspan"},
+		"th":  {"colspan", "rowspan", "scope"},
+		"col": {"span"},
+		"ol":  {"start", "type"},
+	},
+	CSSProperties: set(
+		"align-items", "align-self", "background-color", "background-image", "background-position",
+		"background-repeat", "background-size", "border", "border-bottom", "border-collapse",
+		"border-color", "border-left", "border-radius", "border-right", "border-spacing",
+		"border-style", "border-top", "border-width", "bottom", "box-shadow", "box-sizing", "color",
+		"column-gap", "content", "display", "flex", "flex-basis", "flex-direction", "flex-grow",
+		"flex-shrink", "flex-wrap", "font", "font-family", "font-size", "font-style", "font-weight",
+		"gap", "grid-area", "grid-column", "grid-row", "grid-template-areas", "grid-template-columns",
+		"grid-template-rows", "height", "justify-content", "left", "letter-spacing", "line-height",
+		"margin", "margin-block-end", "margin-block-start", "margin-bottom", "margin-inline-end",
+		"margin-inline-start", "margin-left", "margin-right", "margin-top", "max-height",
+		"max-width", "min-height", "min-width", "opacity", "overflow", "padding", "padding-bottom",
+		"padding-left", "padding-right", "padding-top", "position", "right", "row-gap",
+		"text-align", "text-decoration", "text-transform", "top", "transform", "vertical-align",
+		"white-space", "width", "word-break", "z-index",
+	),
+}
+
+// HTML sanitises an HTML fragment with DefaultPolicy
+func HTML(src string) string {
+	return DefaultPolicy.HTML(src)
+}
+
+// HTML sanitises an HTML fragment. Scripts, event handlers, forms, frames and
+// anything that would make the renderer load a URL (links, remote images,
+// url() in CSS, @import) are removed.
+func (p *Policy) HTML(src string) string {
+	context := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(src), context)
+	if err != nil {
+		// The parser only fails on reader errors, which a string reader never returns
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, n := range nodes {
+		context.AppendChild(n)
+	}
+	p.sanitizeChildren(context)
+	for n := context.FirstChild; n != nil; n = n.NextSibling {
+		if err := html.Render(&sb, n); err != nil {
+			return ""
+		}
+	}
+	return sb.String()
+}
+
+// sanitizeChildren applies the policy to every child of parent
+func (p *Policy) sanitizeChildren(parent *html.Node) {
+	for n := parent.FirstChild; n != nil; {
+		next := n.NextSibling
+		switch n.Type {
+		case html.TextNode:
+		case html.ElementNode:
+			tag := strings.ToLower(n.Data)
+			switch {
+			case p.DropContent[tag]:
+				parent.RemoveChild(n)
+			case !p.Elements[tag]:
+				// Keep the content of unknown elements, then revisit it
+				p.sanitizeChildren(n)
+				for c := n.FirstChild; c != nil; c = n.FirstChild {
+					n.RemoveChild(c)
+					parent.InsertBefore(c, n)
+				}
+				parent.RemoveChild(n)
+			case tag == "style":
+				if !p.sanitizeStyleElement(n) {
+					parent.RemoveChild(n)
+				}
+			default:
+				n.Attr = p.sanitizeAttributes(tag, n.Attr)
+				p.sanitizeChildren(n)
+			}
+		default:
+			// Comments, doctypes and the like
+			parent.RemoveChild(n)
+		}
+		n = next
+	}
+}
+
+// sanitizeStyleElement replaces the stylesheet of a <style> element with its
+// sanitised version and drops its attributes. It reports whether any rule is
+// left.
+func (p *Policy) sanitizeStyleElement(n *html.Node) bool {
+	var css strings.Builder
+	for c := n.FirstChild; c != nil; c = n.FirstChild {
+		if c.Type == html.TextNode {
+			css.WriteString(c.Data)
+		}
+		n.RemoveChild(c)
+	}
+	n.Attr = nil
+	sheet := p.Stylesheet(css.String())
+	if sheet == "" {
+		return false
+	}
+	n.AppendChild(&html.Node{Type: html.TextNode, Data: sheet})
+	return true
+}
+
+// sanitizeAttributes keeps the attributes the policy allows on tag
+func (p *Policy) sanitizeAttributes(tag string, attrs []html.Attribute) []html.Attribute {
+	var kept []html.Attribute
+	for _, attr := range attrs {
+		key := strings.ToLower(attr.Key)
+		if attr.Namespace != "" || !p.attributeAllowed(tag, key) {
+			continue
+		}
+		switch key {
+		case "style":
+			attr.Val = p.Declarations(attr.Val)
+			if attr.Val == "" {
+				continue
+			}
+		case "src":
+			if !isDataImage(attr.Val) {
+				continue
+			}
+		}
+		attr.Key = key
+		kept = append(kept, attr)
+	}
+	return kept
+}
+
+func (p *Policy) attributeAllowed(tag, key string) bool {
+	return matchAttribute(p.GlobalAttributes, key) || matchAttribute(p.Attributes[tag], key)
+}
+
+// matchAttribute reports whether key is one of allowed, where a trailing "*"
+// matches any suffix
+func matchAttribute(allowed []string, key string) bool {
+	for _, a := range allowed {
+		if prefix, ok := strings.CutSuffix(a, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == a {
+			return true
+		}
+	}
+	return false
+}
+
+// isDataImage reports whether src is an image embedded as a data URI, the
+// only kind of image source that does not make the renderer fetch anything
+func isDataImage(src string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(src)), "data:image/")
+}
+
+func set(values ...string) map[string]bool {
+	m := make(map[string]bool, len(values))
+	for _, v := range values {
+		m[v] = true
+	}
+	return m
+}
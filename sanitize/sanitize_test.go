@@ -0,0 +1,106 @@
+package sanitize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "keeps card markup",
+			in:   `<section class="card"><h2 aria-label="Plan">Gold</h2><p style="color: #333">ID 25</p></section>`,
+			want: `<section class="card"><h2 aria-label="Plan">Gold</h2><p style="color:#333">ID 25</p></section>`,
+		},
+		{
+			name: "drops scripts",
+			in:   `<p>Member</p><script>fetch("http://169.254.169.254/")</script>`,
+			want: `<p>Member</p>`,
+		},
+		{
+			name: "drops event handlers",
+			in:   `<div onclick="alert(1)" onload="x()">Card</div><img src="data:image/png;base64,AAAA" onerror="alert(1)">`,
+			want: `<div>Card</div><img src="data:image/png;base64,AAAA"/>`,
+		},
+		{
+			name: "drops remote and local images",
+			in:   `<img src="https://example.com/logo.png" alt="logo"><img src="file:///etc/passwd">`,
+			want: `<img alt="logo"/><img/>`,
+		},
+		{
+			name: "drops links and frames",
+			in:   `<a href="javascript:alert(1)">Call</a><iframe src="http://10.0.0.1/"></iframe><link rel="stylesheet" href="https://example.com/a.css">`,
+			want: `<a>Call</a>`,
+		},
+		{
+			name: "unwraps unknown elements",
+			in:   `<blink><b>Active</b></blink><!-- internal note -->`,
+			want: `<b>Active</b>`,
+		},
+		{
+			name: "escapes text",
+			in:   `<p>Smith &amp; Sons &lt;script&gt;</p>`,
+			want: `<p>Smith &amp; Sons &lt;script&gt;</p>`,
+		},
+		{
+			name: "sanitises style elements",
+			in:   `<style>@import url(https://example.com/a.css); .logo{width:50px;background-image:url(https://placehold.co/50x50)} .x{position:absolute}</style>`,
+			want: `<style>.logo{width:50px}.x{position:absolute}</style>`,
+		},
+		{
+			name: "style elements cannot be closed early",
+			in:   `<style>.a{color:red}</style ><style>.b{content:"</style><script>alert(1)</script>"}</style>`,
+			want: `<style>.a{color:red}</style>&#34;}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HTML(tt.in)
+			if got != tt.want {
+				t.Errorf("HTML() =\n%s\nwant\n%s", got, tt.want)
+			}
+			if strings.Contains(strings.ToLower(got), "<script") {
+				t.Errorf("HTML() kept a script: %s", got)
+			}
+		})
+	}
+}
+
+func TestDeclarations(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "color: red; font-weight: 700", want: "color:red;font-weight:700"},
+		{in: "COLOR: Red", want: "color:Red"},
+		{in: "color: red; behavior: url(x.htc)", want: "color:red"},
+		{in: "width: expression(alert(1))", want: ""},
+		{in: "background-image: url(http://example.com/a.png)", want: ""},
+		{in: "background-image: url('file:///etc/passwd')", want: ""},
+		{in: `background-image: url("data:image/png;base64,AAAA")`, want: `background-image:url("data:image/png;base64,AAAA")`},
+		{in: `background-image: url(data:image/png;base64,AA), url(https://example.com/b.png)`, want: ""},
+		{in: `background-image: u\72l(https://example.com/a.png)`, want: ""},
+		{in: "font-family: 'A;B', sans-serif; color: blue", want: "font-family:'A;B', sans-serif;color:blue"},
+		{in: "color: red /* comment */; position: fixed", want: "color:red;position:fixed"},
+		{in: "unknown-prop: 1; color", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := DefaultPolicy.Declarations(tt.in); got != tt.want {
+			t.Errorf("Declarations(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStylesheet(t *testing.T) {
+	in := `/* card */ .card{color:#000;} @media print { .card{color:red} } @font-face{font-family:x;src:url(https://example.com/x.woff)} .a>*+*{margin-block-start:12px}`
+	want := `.card{color:#000}.a>*+*{margin-block-start:12px}`
+	if got := DefaultPolicy.Stylesheet(in); got != want {
+		t.Errorf("Stylesheet() = %q, want %q", got, want)
+	}
+}
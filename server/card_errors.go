@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"main/card_error"
+	"net/http"
+	"strings"
+)
+
+// failedCardsHeader names the cards left out of a lenient download
+const failedCardsHeader = "X-Failed-Cards"
+
+// ErrorDocument is a JSON:API error document
+type ErrorDocument struct {
+	Errors []ErrorObject `json:"errors"`
+}
+
+// ErrorObject is a JSON:API error object describing one failed card
+type ErrorObject struct {
+	Status string          `json:"status"`
+	Code   string          `json:"code"`
+	Title  string          `json:"title"`
+	Detail string          `json:"detail"`
+	Meta   ErrorObjectMeta `json:"meta"`
+}
+
+// ErrorObjectMeta identifies the card and the stage that failed
+type ErrorObjectMeta struct {
+	CardId string           `json:"cardId"`
+	Stage  card_error.Stage `json:"stage"`
+}
+
+// setFailedCardsHeader lists the ids of the failed cards on a partial response
+func setFailedCardsHeader(w http.ResponseWriter, failed []*card_error.CardError) {
+	if len(failed) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(failed))
+	for _, cardErr := range failed {
+		ids = append(ids, cardErr.CardId)
+	}
+	w.Header().Set(failedCardsHeader, strings.Join(ids, ","))
+}
+
+// writeCardsError answers with a JSON:API error document naming every failed card
+func writeCardsError(w http.ResponseWriter, cardsErr *card_error.CardsError) {
+	status := http.StatusUnprocessableEntity
+	doc := ErrorDocument{Errors: make([]ErrorObject, 0, len(cardsErr.Cards))}
+	for _, cardErr := range cardsErr.Cards {
+		doc.Errors = append(doc.Errors, ErrorObject{
+			Status: fmt.Sprint(status),
+			Code:   "card_failed",
+			Title:  "Failed to process ID card",
+			Detail: cardErr.Err.Error(),
+			Meta: ErrorObjectMeta{
+				CardId: cardErr.CardId,
+				Stage:  cardErr.Stage,
+			},
+		})
+	}
+
+	content, err := json.Marshal(doc)
+	if err != nil {
+		http.Error(w, "Failed to report the failed cards", http.StatusInternalServerError)
+		return
+	}
+
+	setFailedCardsHeader(w, cardsErr.Cards)
+	w.Header().Set("Content-Type", jsonAPIContentType)
+	w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+	w.WriteHeader(status)
+	if _, err := w.Write(content); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"fmt"
+	"main/card_image"
+	"main/to_image"
+	"net/url"
+	"strconv"
+)
+
+// Bounds of the geometry query parameters of /image/idcards, in pixels
+const (
+	maxCardSize     = 4096
+	maxGutter       = 1000
+	maxCornerRadius = 500
+)
+
+// imageGeometry reads the geometry of the merged image from the query. dpi
+// scales the default card size and spacing, which card_width, card_height,
+// gutter and padding override in pixels. background is a hex colour or
+// "transparent", which needs a format with alpha. A single card slot with its
+// padding has to fit limits.MaxCanvasPixels, which bounds dpi and card size
+// together; the canvas of all cards is checked once they are known.
+func imageGeometry(query url.Values, format to_image.Format, limits card_image.Limits) (*to_image.LayoutOptions, error) {
+	dpi := float64(to_image.DefaultDPI)
+	if v := query.Get("dpi"); v != "" {
+		var err error
+		if dpi, err = strconv.ParseFloat(v, 64); err != nil || dpi < 72 || dpi > 600 {
+			return nil, fmt.Errorf("dpi must be a number between 72 and 600")
+		}
+	}
+	geometry := to_image.DefaultLayoutOptions(dpi)
+
+	for _, param := range []struct {
+		name     string
+		dst      *int
+		min, max int
+	}{
+		{"card_width", &geometry.CardWidth, 1, maxCardSize},
+		{"card_height", &geometry.CardHeight, 1, maxCardSize},
+		{"gutter", &geometry.Gutter, 0, maxGutter},
+		{"padding", &geometry.Padding, 0, maxGutter},
+		{"corner_radius", &geometry.CornerRadius, 0, maxCornerRadius},
+	} {
+		v := query.Get(param.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < param.min || n > param.max {
+			return nil, fmt.Errorf("%s must be an integer between %d and %d", param.name, param.min, param.max)
+		}
+		*param.dst = n
+	}
+
+	if v := query.Get("background"); v != "" {
+		background, err := to_image.ParseColor(v)
+		if err != nil {
+			return nil, err
+		}
+		geometry.Background = background
+	}
+	if geometry.Transparent() && !format.HasAlpha() {
+		return nil, to_image.ErrTransparentFormat
+	}
+	if err := geometry.CheckCanvas(1, 1, limits); err != nil {
+		return nil, err
+	}
+	return &geometry, nil
+}
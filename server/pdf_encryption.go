@@ -0,0 +1,47 @@
+package server
+
+import (
+	"fmt"
+	"main/to_pdf"
+	"net/http"
+)
+
+// pdfPasswordHeader carries the password a PDF download is encrypted with. A
+// header keeps the password out of access logs, unlike a query parameter.
+const pdfPasswordHeader = "X-PDF-Password"
+
+// passwordMemberID asks for the PDF password to be the member ID on the cards
+const passwordMemberID = "member_id"
+
+// pdfEncryption reads how a PDF download should be encrypted. The password
+// comes from the X-PDF-Password header, or from the member ID when the
+// password query parameter is "member_id", and the permissions query parameter
+// lists the allowed actions. It returns nil when the request asks for neither.
+func pdfEncryption(r *http.Request) (enc *to_pdf.Encryption, fromMemberID bool, err error) {
+	query := r.URL.Query()
+	password := r.Header.Get(pdfPasswordHeader)
+
+	switch v := query.Get("password"); v {
+	case "":
+	case passwordMemberID:
+		if password != "" {
+			return nil, false, fmt.Errorf("password=%s cannot be combined with the %s header", passwordMemberID, pdfPasswordHeader)
+		}
+		fromMemberID = true
+	default:
+		return nil, false, fmt.Errorf("unknown password source %q", v)
+	}
+
+	perms := to_pdf.DefaultPermissions
+	list, restricted := query["permissions"]
+	if restricted {
+		if perms, err = to_pdf.ParsePermissions(list[0]); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if password == "" && !fromMemberID && !restricted {
+		return nil, false, nil
+	}
+	return &to_pdf.Encryption{UserPassword: password, Permissions: perms}, fromMemberID, nil
+}
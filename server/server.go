@@ -0,0 +1,452 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"log"
+	"main/card_error"
+	"main/card_image"
+	"main/card_source"
+	"main/data"
+	"main/to_image"
+	"main/to_pdf"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Server represents the PDF HTTP server
+type Server struct {
+	router      chi.Router
+	idCards     card_source.IdCardsSource // Provides the ID cards of the requesting member
+	pdfRenderer to_pdf.Renderer           // Renderer used when a request does not pick one
+	rasterizer  to_image.HTMLRasterizer   // Renders HTML cards for the image endpoint
+	timeout     time.Duration             // Upper bound for handling a single request
+	signer      *to_pdf.Signer            // Signs PDFs requested with sign=true, if configured
+	validator   *to_pdf.VeraPDF           // Validates PDFs requested with archival=true, if configured
+	limits      card_image.Limits         // Caps the card images decoded for a request
+}
+
+// DefaultRequestTimeout bounds a request when WithRequestTimeout is not used
+const DefaultRequestTimeout = 2 * time.Minute
+
+// ServerOption configures a Server
+type ServerOption func(*Server)
+
+// WithPDFRenderer sets the default PDF renderer for the server
+func WithPDFRenderer(renderer to_pdf.Renderer) ServerOption {
+	return func(s *Server) {
+		s.pdfRenderer = renderer
+	}
+}
+
+// WithHTMLRasterizer sets how HTML cards are rendered for the image endpoint
+func WithHTMLRasterizer(rasterizer to_image.HTMLRasterizer) ServerOption {
+	return func(s *Server) {
+		s.rasterizer = rasterizer
+	}
+}
+
+// WithRequestTimeout bounds how long the server works on a single request
+func WithRequestTimeout(timeout time.Duration) ServerOption {
+	return func(s *Server) {
+		s.timeout = timeout
+	}
+}
+
+// WithIdCardsSource sets where the server fetches ID cards from
+func WithIdCardsSource(source card_source.IdCardsSource) ServerOption {
+	return func(s *Server) {
+		s.idCards = source
+	}
+}
+
+// WithImageLimits caps the size of the card images decoded for a request
+func WithImageLimits(limits card_image.Limits) ServerOption {
+	return func(s *Server) {
+		s.limits = limits
+	}
+}
+
+// WithSigner lets clients request PDFs signed by the issuer
+func WithSigner(signer *to_pdf.Signer) ServerOption {
+	return func(s *Server) {
+		s.signer = signer
+	}
+}
+
+// WithPDFAValidator runs veraPDF over every archival PDF before it is returned
+func WithPDFAValidator(validator *to_pdf.VeraPDF) ServerOption {
+	return func(s *Server) {
+		s.validator = validator
+	}
+}
+
+// NewServer creates a new PDF server
+func NewServer(opts ...ServerOption) *Server {
+	s := &Server{
+		router:      chi.NewRouter(),
+		pdfRenderer: to_pdf.DefaultRenderer(),
+		rasterizer:  to_image.DefaultHTMLRasterizer(),
+		timeout:     DefaultRequestTimeout,
+		idCards: card_source.NewStaticSource(data.IdCardsResponseSchema{ // Serve mock ID cards by default
+			Data: []data.IdCard{
+				data.MockImageIdCardFront,
+				data.MockImageIdCardBack,
+				data.MockIdCardFront,
+				data.MockIdCardBack,
+				data.MockHTMLIdCardFront,
+				data.MockHTMLIdCardBack,
+				data.MockHTMLIdCardBoth,
+			},
+		}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.routes()
+	return s
+}
+
+// routes sets up all the routes for the PDF server
+func (s *Server) routes() {
+	s.router.Use(s.withTimeout)
+	s.router.Get(pdfIDCardsPath, s.handleGetIDCardsPDF())
+	s.router.Get(imageIDCardsPath, s.handleGetIDCardsImage())
+	s.router.Get(templateExtensionIDCardsPath, s.handleGetIDCardsTemplateExtension())
+}
+
+// withTimeout bounds the context of every request by the server timeout so all
+// generation stages stop once it expires or the client disconnects
+func (s *Server) withTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ServeHTTP implements the http.Handler interface
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// writeResponse is a helper function to write content to response with proper error handling
+func writeResponse(w http.ResponseWriter, content []byte, fileName string, contentType string) {
+	w.Header().Set("Content-Disposition", "attachment; filename="+fileName)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+
+	if _, err := io.Copy(w, bytes.NewReader(content)); err != nil {
+		log.Printf("Error writing response: %v", err)
+		// We can't change the status code at this point as headers are already sent
+	}
+}
+
+// writeGenerationError reports a failed generation. Requests abandoned by the
+// client get no response body, those that ran out of time get a 504.
+func writeGenerationError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	log.Printf("%s: %v", msg, err)
+	switch {
+	case errors.Is(r.Context().Err(), context.Canceled):
+		// The client went away, nobody is listening for the response
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(r.Context().Err(), context.DeadlineExceeded):
+		http.Error(w, msg+": timed out", http.StatusGatewayTimeout)
+	case errors.Is(err, card_image.ErrRequestLimit):
+		http.Error(w, msg+": "+card_image.ErrRequestLimit.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
+
+// fetchIdCards loads the ID cards for the member making the request, ordered as
+// requested by the sort query parameter. It writes an error response and
+// returns false when the cards cannot be loaded.
+func (s *Server) fetchIdCards(w http.ResponseWriter, r *http.Request) (data.IdCardsResponseSchema, bool) {
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy != "" && sortBy != "benefit" {
+		http.Error(w, fmt.Sprintf("unknown sort %q", sortBy), http.StatusBadRequest)
+		return data.IdCardsResponseSchema{}, false
+	}
+
+	idCardsResp, err := s.idCards.GetIdCards(r.Context(), card_source.ParamsFromRequest(r))
+	if err != nil {
+		log.Printf("Failed to fetch ID cards: %v", err)
+
+		var upstreamErr *card_source.UpstreamError
+		if errors.As(err, &upstreamErr) {
+			switch upstreamErr.StatusCode {
+			case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+				http.Error(w, http.StatusText(upstreamErr.StatusCode), upstreamErr.StatusCode)
+				return idCardsResp, false
+			}
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, "Failed to fetch ID cards: timed out", http.StatusGatewayTimeout)
+			return idCardsResp, false
+		}
+		http.Error(w, "Failed to fetch ID cards", http.StatusBadGateway)
+		return idCardsResp, false
+	}
+
+	if sortBy == "benefit" {
+		idCardsResp.Data = data.SortIdCardsByBenefit(idCardsResp.Data)
+	}
+	return idCardsResp, true
+}
+
+// handleGetIDCardsTemplateExtension returns a handler function describing the ID cards
+// and their download options as a JSON:API document
+func (s *Server) handleGetIDCardsTemplateExtension() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idCardsResp, ok := s.fetchIdCards(w, r)
+		if !ok {
+			return
+		}
+
+		doc := buildTemplateExtensionDocument(idCardsResp, r.URL.Query())
+
+		content, err := json.Marshal(doc)
+		if err != nil {
+			http.Error(w, "Failed to build template extension", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonAPIContentType)
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		if _, err := w.Write(content); err != nil {
+			log.Printf("Error writing response: %v", err)
+		}
+	}
+}
+
+func (s *Server) handleGetIDCardsImage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		layout, err := to_image.ParseLayout(query.Get("layout"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var columns int
+		if v := query.Get("columns"); v != "" {
+			if layout != to_image.LayoutGrid {
+				http.Error(w, "columns only applies to layout=grid", http.StatusBadRequest)
+				return
+			}
+			if columns, err = strconv.Atoi(v); err != nil || columns < 1 || columns > 16 {
+				http.Error(w, "columns must be an integer between 1 and 16", http.StatusBadRequest)
+				return
+			}
+		}
+
+		format, err := to_image.NegotiateFormat(query.Get("format"), r.Header.Get("Accept"))
+		if err != nil {
+			status := http.StatusBadRequest
+			if query.Get("format") == "" {
+				status = http.StatusNotAcceptable
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		var quality int
+		if v := query.Get("quality"); v != "" {
+			if quality, err = strconv.Atoi(v); err != nil || quality < 1 || quality > 100 {
+				http.Error(w, "quality must be an integer between 1 and 100", http.StatusBadRequest)
+				return
+			}
+		}
+
+		interpolator, err := to_image.ParseInterpolator(query.Get("interpolator"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		geometry, err := imageGeometry(query, format, s.limits)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var strict bool
+		if v := query.Get("strict"); v != "" {
+			if strict, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "strict must be a boolean", http.StatusBadRequest)
+				return
+			}
+		}
+
+		idCardsResp, ok := s.fetchIdCards(w, r)
+		if !ok {
+			return
+		}
+
+		// Generate the merged image
+		response, err := to_image.MergeImagesWithOptions(r.Context(), idCardsResp, to_image.Options{
+			Layout:         layout,
+			Columns:        columns,
+			Format:         format,
+			Quality:        quality,
+			HTMLRasterizer: s.rasterizer,
+			Geometry:       geometry,
+			Strict:         strict,
+			Limits:         s.limits,
+			Interpolator:   interpolator,
+		})
+		var cardsErr *card_error.CardsError
+		if errors.As(err, &cardsErr) {
+			log.Printf("Failed to generate image: %v", err)
+			writeCardsError(w, cardsErr)
+			return
+		}
+		if errors.Is(err, card_image.ErrCanvasLimit) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			writeGenerationError(w, r, "Failed to generate image", err)
+			return
+		}
+
+		setFailedCardsHeader(w, response.FailedCards)
+
+		writeResponse(w, response.ImageContent, response.FileName, response.ContentType)
+	}
+}
+
+// handleGetIDCardsPDF returns a handler function for generating PDF from ID cards
+func (s *Server) handleGetIDCardsPDF() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		renderer := s.pdfRenderer
+		if name := r.URL.Query().Get("renderer"); name != "" {
+			var err error
+			if renderer, err = to_pdf.GetRenderer(name); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		layout, err := to_pdf.ParseLayout(r.URL.Query().Get("layout"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		theme := to_pdf.DefaultTheme()
+		if name := r.URL.Query().Get("theme"); name != "" {
+			if theme, err = to_pdf.GetTheme(name); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var textLayer bool
+		if v := r.URL.Query().Get("text_layer"); v != "" {
+			if textLayer, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "text_layer must be a boolean", http.StatusBadRequest)
+				return
+			}
+		}
+
+		encryption, passwordFromMemberID, err := pdfEncryption(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var archival bool
+		if v := r.URL.Query().Get("archival"); v != "" {
+			if archival, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "archival must be a boolean", http.StatusBadRequest)
+				return
+			}
+		}
+		if archival && encryption != nil {
+			http.Error(w, to_pdf.ErrArchivalEncryption.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var signer *to_pdf.Signer
+		if v := r.URL.Query().Get("sign"); v != "" {
+			sign, err := strconv.ParseBool(v)
+			if err != nil {
+				http.Error(w, "sign must be a boolean", http.StatusBadRequest)
+				return
+			}
+			if sign {
+				if s.signer == nil {
+					http.Error(w, "PDF signing is not configured", http.StatusBadRequest)
+					return
+				}
+				signer = s.signer
+			}
+		}
+		if signer != nil && encryption != nil {
+			http.Error(w, to_pdf.ErrSignedEncryption.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var strict bool
+		if v := r.URL.Query().Get("strict"); v != "" {
+			if strict, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "strict must be a boolean", http.StatusBadRequest)
+				return
+			}
+		}
+
+		idCardsResp, ok := s.fetchIdCards(w, r)
+		if !ok {
+			return
+		}
+
+		if passwordFromMemberID {
+			if encryption.UserPassword, err = to_pdf.MemberIDPassword(idCardsResp); err != nil {
+				http.Error(w, "Failed to protect PDF: "+err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+		}
+
+		// Generate the PDF
+		response, err := to_pdf.GeneratePDF(r.Context(), idCardsResp, to_pdf.Options{
+			Renderer:   renderer,
+			Layout:     layout,
+			Theme:      theme,
+			TextLayer:  textLayer,
+			Encryption: encryption,
+			Archival:   archival,
+			Signer:     signer,
+			Validator:  s.validator,
+			Limits:     s.limits,
+			Strict:     strict,
+		})
+		var cardsErr *card_error.CardsError
+		if errors.As(err, &cardsErr) {
+			log.Printf("Failed to generate PDF: %v", err)
+			writeCardsError(w, cardsErr)
+			return
+		}
+		if err != nil {
+			writeGenerationError(w, r, "Failed to generate PDF", err)
+			return
+		}
+
+		setFailedCardsHeader(w, response.FailedCards)
+
+		writeResponse(w, response.PDFContent, response.FileName, "application/pdf")
+	}
+}
+
+// StartServer starts the PDF server on the specified address
+func StartServer(addr string, opts ...ServerOption) error {
+	server := NewServer(opts...)
+	fmt.Printf("Starting PDF server on %s\n", addr)
+	return http.ListenAndServe(addr, server)
+}
@@ -0,0 +1,329 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"main/card_image"
+	"main/card_source"
+	"main/data"
+	"main/to_pdf"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakeRenderer stands in for wkhtmltopdf and Chrome, which tests cannot run
+type fakeRenderer struct{}
+
+func (fakeRenderer) Name() string {
+	return "fake"
+}
+
+func (fakeRenderer) Render(context.Context, []byte, to_pdf.Page) ([]byte, error) {
+	return []byte("%PDF-fake"), nil
+}
+
+// solidCard returns a base64 image card of a single colour
+func solidCard(t *testing.T, id string, c color.Color, width, height int) data.IdCard {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return data.IdCard{
+		Id: id,
+		Attributes: data.IdCardAttributes{
+			Type:   data.IdCardAttributesTypeBase64,
+			Face:   data.IdCardAttributesFaceFront,
+			Source: base64.StdEncoding.EncodeToString(buf.Bytes()),
+		},
+	}
+}
+
+// brokenCard returns an image card whose source cannot be decoded
+func brokenCard(id string) data.IdCard {
+	return data.IdCard{
+		Id: id,
+		Attributes: data.IdCardAttributes{
+			Type:   data.IdCardAttributesTypeBase64,
+			Face:   data.IdCardAttributesFaceBack,
+			Source: "not base64!",
+		},
+	}
+}
+
+// newTestServer serves cards with a fake PDF renderer
+func newTestServer(cards []data.IdCard, opts ...ServerOption) *Server {
+	opts = append([]ServerOption{
+		WithPDFRenderer(fakeRenderer{}),
+		WithIdCardsSource(card_source.NewStaticSource(data.IdCardsResponseSchema{Data: cards})),
+	}, opts...)
+	return NewServer(opts...)
+}
+
+func get(s *Server, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, values := range header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTemplateExtensionForwardsCardSelection(t *testing.T) {
+	s := newTestServer([]data.IdCard{data.MockImageIdCardFront})
+	rec := get(s, templateExtensionIDCardsPath+"?userId=u1&benefitType=dental&sort=benefit&format=png&sign=true&layout=grid&password=member_id", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %q", rec.Code, rec.Body)
+	}
+
+	var doc TemplateExtensionDocument
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid document: %v", err)
+	}
+	want := url.Values{"userId": {"u1"}, "benefitType": {"dental"}, "sort": {"benefit"}}
+	for name, link := range map[string]string{"self": doc.Links.Self, "pdf": doc.Links.PDF, "image": doc.Links.Image} {
+		u, err := url.Parse(link)
+		if err != nil {
+			t.Fatalf("%s link %q: %v", name, link, err)
+		}
+		if got := u.Query(); got.Encode() != want.Encode() {
+			t.Errorf("%s link query = %q, want %q", name, got.Encode(), want.Encode())
+		}
+	}
+	for _, format := range doc.Meta.Formats[1:] {
+		u, _ := url.Parse(format.Href)
+		query := u.Query()
+		if query.Get("format") != format.Name || query.Has("sign") || query.Has("layout") || query.Get("userId") != "u1" {
+			t.Errorf("%s href = %q", format.Name, format.Href)
+		}
+	}
+}
+
+func TestTemplateExtensionRejectsUnknownSort(t *testing.T) {
+	s := newTestServer([]data.IdCard{data.MockImageIdCardFront})
+	if rec := get(s, templateExtensionIDCardsPath+"?sort=date", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestImageQueryValidation(t *testing.T) {
+	s := newTestServer([]data.IdCard{solidCard(t, "medical", color.Black, 20, 10)})
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"layout=diagonal", "unknown layout"},
+		{"format=gif", "unsupported image format"},
+		{"quality=0", "quality must be"},
+		{"quality=101", "quality must be"},
+		{"interpolator=lanczos", "unknown interpolator"},
+		{"strict=maybe", "strict must be a boolean"},
+		{"dpi=10", "dpi must be"},
+		{"dpi=1200", "dpi must be"},
+		{"card_width=0", "card_width must be"},
+		{"card_height=5000", "card_height must be"},
+		{"gutter=-1", "gutter must be"},
+		{"padding=x", "padding must be"},
+		{"corner_radius=501", "corner_radius must be"},
+		{"background=white", "invalid colour"},
+		{"background=transparent", "transparent backgrounds"},
+		{"background=%23ff000080&format=jpeg", "transparent backgrounds"},
+		{"columns=2", "columns only applies to layout=grid"},
+		{"layout=grid&columns=0", "columns must be"},
+		{"layout=grid&columns=17", "columns must be"},
+	}
+	for _, tt := range tests {
+		rec := get(s, imageIDCardsPath+"?"+tt.query, nil)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("%s: status = %d, body %q, want 400 %q", tt.query, rec.Code, rec.Body, tt.want)
+		}
+	}
+
+	rec := get(s, imageIDCardsPath, http.Header{"Accept": {"image/gif"}})
+	if rec.Code != http.StatusNotAcceptable {
+		t.Errorf("Accept: image/gif status = %d, want 406", rec.Code)
+	}
+}
+
+func TestImageStrict(t *testing.T) {
+	s := newTestServer([]data.IdCard{solidCard(t, "medical", color.Black, 20, 10), brokenCard("dental")})
+
+	rec := get(s, imageIDCardsPath+"?format=png", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get(failedCardsHeader) != "dental" {
+		t.Errorf("lenient: status = %d, %s = %q", rec.Code, failedCardsHeader, rec.Header().Get(failedCardsHeader))
+	}
+
+	rec = get(s, imageIDCardsPath+"?format=png&strict=true", nil)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("strict: status = %d, want 422", rec.Code)
+	}
+	var doc ErrorDocument
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid error document: %v", err)
+	}
+	if len(doc.Errors) != 1 || doc.Errors[0].Meta.CardId != "dental" || doc.Errors[0].Meta.Stage != "decode" {
+		t.Errorf("errors = %+v", doc.Errors)
+	}
+}
+
+func TestImageGeometry(t *testing.T) {
+	cards := []data.IdCard{solidCard(t, "medical", color.Black, 20, 10), solidCard(t, "dental", color.Black, 20, 10)}
+	s := newTestServer(cards)
+
+	tests := []struct {
+		query string
+		want  image.Rectangle
+	}{
+		{"card_width=100&card_height=50&gutter=4&padding=8", image.Rect(0, 0, 116, 120)},
+		{"dpi=150&padding=0", image.Rect(0, 0, 506, 653)},
+		{"layout=grid&columns=2&card_width=100&card_height=50&gutter=0&padding=0", image.Rect(0, 0, 200, 50)},
+		{"layout=horizontal&card_width=100&card_height=50&gutter=10&padding=0&background=transparent", image.Rect(0, 0, 210, 50)},
+	}
+	for _, tt := range tests {
+		rec := get(s, imageIDCardsPath+"?format=png&"+tt.query, nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, body %q", tt.query, rec.Code, rec.Body)
+			continue
+		}
+		cfg, err := png.DecodeConfig(rec.Body)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		if got := image.Rect(0, 0, cfg.Width, cfg.Height); got != tt.want {
+			t.Errorf("%s: bounds = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestImageCanvasLimit(t *testing.T) {
+	var cards []data.IdCard
+	for _, id := range []string{"medical", "dental", "vision", "pharmacy", "life"} {
+		cards = append(cards, solidCard(t, id, color.Black, 20, 10))
+	}
+
+	tests := []struct {
+		name   string
+		limits card_image.Limits
+		query  string
+		want   int
+	}{
+		{"default card size", card_image.Limits{}, "", http.StatusOK},
+		{"large cards", card_image.Limits{}, "card_width=4096&card_height=4096", http.StatusBadRequest},
+		{"low dpi", card_image.Limits{MaxCanvasPixels: 1_000_000}, "dpi=72", http.StatusOK},
+		// A single card slot at 600 dpi is already over the limit
+		{"high dpi", card_image.Limits{MaxCanvasPixels: 1_000_000}, "dpi=600", http.StatusBadRequest},
+		{"high dpi small cards", card_image.Limits{MaxCanvasPixels: 1_000_000}, "dpi=600&card_width=200&card_height=100", http.StatusOK},
+	}
+	for _, tt := range tests {
+		s := newTestServer(cards, WithImageLimits(tt.limits))
+		rec := get(s, imageIDCardsPath+"?format=png&"+tt.query, nil)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d, body %q", tt.name, rec.Code, tt.want, rec.Body)
+			continue
+		}
+		if tt.want == http.StatusBadRequest && !strings.Contains(rec.Body.String(), card_image.ErrCanvasLimit.Error()) {
+			t.Errorf("%s: body = %q, want %q", tt.name, rec.Body, card_image.ErrCanvasLimit)
+		}
+	}
+}
+
+func TestImageCanvasLimitLayouts(t *testing.T) {
+	var cards []data.IdCard
+	for _, id := range []string{"medical", "dental", "vision", "pharmacy", "life"} {
+		cards = append(cards, solidCard(t, id, color.Black, 20, 10))
+	}
+	// Five cards side by side make a 550 × 60 image, a grid a 330 × 120 one
+	s := newTestServer(cards, WithImageLimits(card_image.Limits{MaxCanvasPixels: 550 * 60}))
+	geometry := "&card_width=100&card_height=50&padding=5"
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"layout=horizontal&gutter=10", http.StatusOK},
+		{"layout=grid&gutter=10", http.StatusBadRequest},
+		{"layout=grid&columns=2&gutter=10", http.StatusBadRequest},
+		{"layout=grid&columns=5&gutter=10", http.StatusOK},
+		{"layout=horizontal&gutter=11", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := get(s, imageIDCardsPath+"?format=png"+geometry+"&"+tt.query, nil)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d, body %q", tt.query, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
+
+func TestPDFQueryValidation(t *testing.T) {
+	s := newTestServer([]data.IdCard{solidCard(t, "medical", color.Black, 20, 10)})
+	tests := []struct {
+		query  string
+		header http.Header
+		want   string
+	}{
+		{"renderer=prince", nil, "unknown PDF renderer"},
+		{"layout=grid", nil, "unknown layout"},
+		{"theme=missing", nil, "missing"},
+		{"text_layer=maybe", nil, "text_layer must be a boolean"},
+		{"password=birthday", nil, "unknown password source"},
+		{"password=member_id", http.Header{pdfPasswordHeader: {"secret"}}, "cannot be combined"},
+		{"permissions=fly", nil, "fly"},
+		{"archival=maybe", nil, "archival must be a boolean"},
+		{"archival=true", http.Header{pdfPasswordHeader: {"secret"}}, to_pdf.ErrArchivalEncryption.Error()},
+		{"sign=maybe", nil, "sign must be a boolean"},
+		{"sign=true", nil, "PDF signing is not configured"},
+		{"strict=maybe", nil, "strict must be a boolean"},
+	}
+	for _, tt := range tests {
+		rec := get(s, pdfIDCardsPath+"?"+tt.query, tt.header)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("%s: status = %d, body %q, want 400 %q", tt.query, rec.Code, rec.Body, tt.want)
+		}
+	}
+}
+
+func TestPDFUsesDefaultRenderer(t *testing.T) {
+	s := newTestServer([]data.IdCard{solidCard(t, "medical", color.Black, 20, 10)})
+	rec := get(s, pdfIDCardsPath+"?layout=paired&text_layer=true", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/pdf" || rec.Body.String() != "%PDF-fake" {
+		t.Errorf("status = %d, Content-Type = %q, body %q", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+}
+
+func TestPDFStrict(t *testing.T) {
+	s := newTestServer([]data.IdCard{solidCard(t, "medical", color.Black, 20, 10), brokenCard("dental")})
+
+	rec := get(s, pdfIDCardsPath, nil)
+	if rec.Code != http.StatusOK || rec.Header().Get(failedCardsHeader) != "dental" {
+		t.Errorf("lenient: status = %d, %s = %q", rec.Code, failedCardsHeader, rec.Header().Get(failedCardsHeader))
+	}
+
+	rec = get(s, pdfIDCardsPath+"?strict=true", nil)
+	if rec.Code != http.StatusUnprocessableEntity || rec.Header().Get("Content-Type") != jsonAPIContentType {
+		t.Fatalf("strict: status = %d, Content-Type = %q, want 422 JSON:API", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var doc ErrorDocument
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid error document: %v", err)
+	}
+	if len(doc.Errors) != 1 || doc.Errors[0].Meta.CardId != "dental" || doc.Errors[0].Meta.Stage != "decode" {
+		t.Errorf("errors = %+v", doc.Errors)
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"main/data"
+	"main/to_image"
+	"net/url"
+)
+
+const (
+	pdfIDCardsPath   = "/pdf/idcards"
+	imageIDCardsPath = "/image/idcards"
+
+	templateExtensionIDCardsPath = "/template-extension/idcards"
+
+	jsonAPIContentType = "application/vnd.api+json"
+)
+
+// cardSelectionParams are the query parameters that pick which cards are
+// returned, and so are carried over to the download links
+var cardSelectionParams = []string{"userId", "benefitId", "benefitType", "sort"}
+
+// TemplateExtensionDocument is the JSON:API document returned by the template extension endpoint
+type TemplateExtensionDocument struct {
+	Data  []TemplateExtensionCard `json:"data"`
+	Links TemplateExtensionLinks  `json:"links"`
+	Meta  TemplateExtensionMeta   `json:"meta"`
+}
+
+// TemplateExtensionCard is a JSON:API resource object describing a single ID card face
+type TemplateExtensionCard struct {
+	Id         string                          `json:"id"`
+	Type       data.IdCardType                 `json:"type"`
+	Attributes TemplateExtensionCardAttributes `json:"attributes"`
+}
+
+// TemplateExtensionCardAttributes holds the per-card metadata shown by the front-end
+type TemplateExtensionCardAttributes struct {
+	Face        data.IdCardAttributesFace         `json:"face"`
+	BenefitId   *string                           `json:"benefitId,omitempty"`
+	BenefitType *data.IdCardAttributesBenefitType `json:"benefitType,omitempty"`
+	AltText     string                            `json:"altText"`
+	SourceType  data.IdCardAttributesType         `json:"sourceType"`
+}
+
+// TemplateExtensionLinks holds the top-level JSON:API links of the document
+type TemplateExtensionLinks struct {
+	Self  string `json:"self"`
+	PDF   string `json:"pdf"`
+	Image string `json:"image"`
+}
+
+// TemplateExtensionMeta holds the download options available for the cards
+type TemplateExtensionMeta struct {
+	Formats []TemplateExtensionFormat `json:"formats"`
+}
+
+// TemplateExtensionFormat describes a downloadable output format
+type TemplateExtensionFormat struct {
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	Href        string `json:"href"`
+}
+
+// buildTemplateExtensionDocument builds the template extension document for the
+// given cards. The card selection parameters of query are forwarded to the
+// download links so they resolve the same cards as the current request; any
+// other parameter is meant for this request only and is dropped.
+func buildTemplateExtensionDocument(idCardsResp data.IdCardsResponseSchema, query url.Values) TemplateExtensionDocument {
+	query = cardSelection(query)
+	links := TemplateExtensionLinks{
+		Self:  withQuery(templateExtensionIDCardsPath, query),
+		PDF:   withQuery(pdfIDCardsPath, query),
+		Image: withQuery(imageIDCardsPath, query),
+	}
+
+	cards := make([]TemplateExtensionCard, 0, len(idCardsResp.Data))
+	for _, card := range idCardsResp.Data {
+		cards = append(cards, TemplateExtensionCard{
+			Id:   card.Id,
+			Type: card.Type,
+			Attributes: TemplateExtensionCardAttributes{
+				Face:        card.Attributes.Face,
+				BenefitId:   card.Attributes.BenefitId,
+				BenefitType: card.Attributes.BenefitType,
+				AltText:     card.Attributes.AltText,
+				SourceType:  card.Attributes.Type,
+			},
+		})
+	}
+
+	formats := []TemplateExtensionFormat{
+		{Name: "pdf", ContentType: "application/pdf", Href: links.PDF},
+	}
+	for _, format := range to_image.Formats() {
+		imageQuery := url.Values{}
+		for k, v := range query {
+			imageQuery[k] = v
+		}
+		imageQuery.Set("format", string(format))
+		formats = append(formats, TemplateExtensionFormat{
+			Name:        string(format),
+			ContentType: format.ContentType(),
+			Href:        withQuery(imageIDCardsPath, imageQuery),
+		})
+	}
+
+	return TemplateExtensionDocument{
+		Data:  cards,
+		Links: links,
+		Meta: TemplateExtensionMeta{
+			Formats: formats,
+		},
+	}
+}
+
+// cardSelection returns the card selection parameters of query
+func cardSelection(query url.Values) url.Values {
+	selection := url.Values{}
+	for _, name := range cardSelectionParams {
+		if v, ok := query[name]; ok {
+			selection[name] = v
+		}
+	}
+	return selection
+}
+
+func withQuery(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
@@ -0,0 +1,60 @@
+package to_image
+
+import (
+	"archive/zip"
+	"fmt"
+	"image"
+	"io"
+	"main/data"
+	"strings"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+// ZipContentType is the content type of LayoutZip responses
+const ZipContentType = "application/zip"
+
+// writeZip writes one image per card into a ZIP archive, each scaled into a
+// single card slot of geometry. Entries are numbered in card order and named
+// after the card id, e.g. "01_medical-front.png".
+func writeZip(w io.Writer, images []image.Image, cards []data.IdCard, geometry LayoutOptions, kernel draw.Interpolator, format Format, quality int) error {
+	zw := zip.NewWriter(w)
+	modified := time.Now()
+	for i, img := range images {
+		cardImg, err := mergeImagesGrid([]image.Image{img}, 1, geometry, kernel)
+		if err != nil {
+			return err
+		}
+
+		// The images are compressed already, deflating them again only costs time
+		entry, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     fmt.Sprintf("%02d_%s.%s", i+1, entryName(cards[i].Id), format.Extension()),
+			Method:   zip.Store,
+			Modified: modified,
+		})
+		if err != nil {
+			return err
+		}
+		if err := encodeImage(entry, cardImg, format, quality); err != nil {
+			return fmt.Errorf("card %s: %w", cards[i].Id, err)
+		}
+	}
+	return zw.Close()
+}
+
+// entryName keeps the characters of a card id that are safe in a file name on
+// every platform
+func entryName(id string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, id)
+	if strings.Trim(name, "._") == "" {
+		return "card"
+	}
+	return name
+}
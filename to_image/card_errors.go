@@ -0,0 +1,9 @@
+package to_image
+
+import "main/card_error"
+
+// stageError tags err with the stage it happened in; the card id is filled in
+// by the caller that knows which card was processed
+func stageError(stage card_error.Stage, err error) *card_error.CardError {
+	return &card_error.CardError{Stage: stage, Err: err}
+}
@@ -0,0 +1,62 @@
+package to_image
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"main/data"
+	"main/fetcher"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMergeImagesCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cards := data.IdCardsResponseSchema{
+		Data: []data.IdCard{solidCard("card-front", data.IdCardAttributesFaceFront, color.White, 200, 126)},
+	}
+	_, err := MergeImagesWithOptions(ctx, cards, Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("MergeImagesWithOptions() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestMergeImagesFetchTimeout(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	remote := data.MockIdCardFront
+	remote.Attributes.Source = slow.URL + "/card.png"
+	cards := data.IdCardsResponseSchema{
+		Data: []data.IdCard{
+			remote,
+			solidCard("card-back", data.IdCardAttributesFaceBack, color.White, 200, 126),
+		},
+	}
+
+	start := time.Now()
+	resp, err := MergeImagesWithOptions(context.Background(), cards, Options{
+		Timeouts: Timeouts{Fetch: 50 * time.Millisecond},
+		Fetcher:  fetcher.New(fetcher.Config{AllowPrivateNetworks: true}),
+	})
+	if err != nil {
+		t.Fatalf("MergeImagesWithOptions() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("MergeImagesWithOptions() took %v, fetch timeout not applied", elapsed)
+	}
+	if len(resp.ImageContent) == 0 {
+		t.Error("MergeImagesWithOptions() returned an empty image")
+	}
+}
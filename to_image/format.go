@@ -0,0 +1,166 @@
+package to_image
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/tiff"
+)
+
+// Format is an output encoding for the merged image
+type Format string
+
+const (
+	FormatJPEG Format = "jpeg"
+	FormatPNG  Format = "png"
+	FormatWebP Format = "webp"
+	FormatTIFF Format = "tiff"
+)
+
+// DefaultFormat is used when the client expresses no preference. JPEG keeps
+// the memory footprint and the download small.
+const DefaultFormat = FormatJPEG
+
+// DefaultJPEGQuality is the JPEG quality used when none is requested
+const DefaultJPEGQuality = 90
+
+type formatSpec struct {
+	contentType string
+	extension   string
+	alpha       bool
+	encode      func(w io.Writer, img image.Image, quality int) error
+}
+
+var formats = map[Format]formatSpec{
+	FormatJPEG: {
+		contentType: "image/jpeg",
+		extension:   "jpg",
+		encode: func(w io.Writer, img image.Image, quality int) error {
+			return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+		},
+	},
+	FormatPNG: {
+		contentType: "image/png",
+		extension:   "png",
+		alpha:       true,
+		encode: func(w io.Writer, img image.Image, _ int) error {
+			return png.Encode(w, img)
+		},
+	},
+	FormatWebP: {
+		contentType: "image/webp",
+		extension:   "webp",
+		alpha:       true,
+		encode: func(w io.Writer, img image.Image, _ int) error {
+			return nativewebp.Encode(w, img, nil)
+		},
+	},
+	FormatTIFF: {
+		contentType: "image/tiff",
+		extension:   "tiff",
+		alpha:       true,
+		encode: func(w io.Writer, img image.Image, _ int) error {
+			return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate, Predictor: true})
+		},
+	},
+}
+
+// Formats returns all supported formats in a stable order
+func Formats() []Format {
+	return []Format{FormatJPEG, FormatPNG, FormatWebP, FormatTIFF}
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	return formats[f].contentType
+}
+
+// Extension returns the file extension of the format, without the dot
+func (f Format) Extension() string {
+	return formats[f].extension
+}
+
+// HasAlpha reports whether the format keeps transparency
+func (f Format) HasAlpha() bool {
+	return formats[f].alpha
+}
+
+// ParseFormat converts a format name, file extension or MIME type into a Format
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, f := range Formats() {
+		spec := formats[f]
+		if name == string(f) || name == spec.extension || name == spec.contentType {
+			return f, nil
+		}
+	}
+	if name == "tif" {
+		return FormatTIFF, nil
+	}
+	return "", fmt.Errorf("unsupported image format %q", name)
+}
+
+// NegotiateFormat picks the output format. An explicit format name wins;
+// otherwise the Accept header is honoured by quality value, falling back to
+// DefaultFormat for wildcards or when no header is sent.
+func NegotiateFormat(format, accept string) (Format, error) {
+	if format != "" {
+		return ParseFormat(format)
+	}
+	if strings.TrimSpace(accept) == "" {
+		return DefaultFormat, nil
+	}
+
+	type candidate struct {
+		format Format
+		q      float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		if mediaType == "*/*" || mediaType == "image/*" {
+			candidates = append(candidates, candidate{format: DefaultFormat, q: q})
+		} else if f, err := ParseFormat(mediaType); err == nil && strings.Contains(mediaType, "/") {
+			candidates = append(candidates, candidate{format: f, q: q})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("none of the accepted types %q is supported", accept)
+	}
+
+	// Highest quality first; ties keep the client's order
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	return candidates[0].format, nil
+}
+
+// encodeImage writes img in the given format. quality only applies to JPEG.
+func encodeImage(w io.Writer, img image.Image, format Format, quality int) error {
+	spec, ok := formats[format]
+	if !ok {
+		return fmt.Errorf("unsupported image format %q", format)
+	}
+	return spec.encode(w, img, quality)
+}
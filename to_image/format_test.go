@@ -0,0 +1,74 @@
+package to_image
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"main/data"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		accept  string
+		want    Format
+		wantErr bool
+	}{
+		{name: "default", want: FormatJPEG},
+		{name: "explicit format wins", format: "png", accept: "image/webp", want: FormatPNG},
+		{name: "extension alias", format: "jpg", want: FormatJPEG},
+		{name: "tif alias", format: "tif", want: FormatTIFF},
+		{name: "unknown format", format: "bmp", wantErr: true},
+		{name: "accept single", accept: "image/webp", want: FormatWebP},
+		{name: "accept by quality", accept: "image/png;q=0.5, image/tiff;q=0.8", want: FormatTIFF},
+		{name: "accept skips unsupported", accept: "image/avif, image/png;q=0.1", want: FormatPNG},
+		{name: "accept wildcard", accept: "text/html, */*;q=0.1", want: FormatJPEG},
+		{name: "accept nothing supported", accept: "image/avif", wantErr: true},
+		{name: "accept q zero", accept: "image/png;q=0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NegotiateFormat(tt.format, tt.accept)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NegotiateFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NegotiateFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeImagesFormats(t *testing.T) {
+	cards := data.IdCardsResponseSchema{
+		Data: []data.IdCard{solidCard("card-front", data.IdCardAttributesFaceFront, color.RGBA{R: 255, A: 255}, 200, 126)},
+	}
+	magic := map[Format][]byte{
+		FormatJPEG: {0xFF, 0xD8, 0xFF},
+		FormatPNG:  []byte("\x89PNG"),
+		FormatWebP: []byte("RIFF"),
+		FormatTIFF: []byte("II*\x00"),
+	}
+
+	for _, format := range Formats() {
+		t.Run(string(format), func(t *testing.T) {
+			resp, err := MergeImagesWithOptions(context.Background(), cards, Options{Format: format})
+			if err != nil {
+				t.Fatalf("MergeImagesWithOptions() error = %v", err)
+			}
+			if !bytes.HasPrefix(resp.ImageContent, magic[format]) {
+				t.Errorf("content does not start with %q", magic[format])
+			}
+			if resp.ContentType != format.ContentType() {
+				t.Errorf("ContentType = %q, want %q", resp.ContentType, format.ContentType())
+			}
+			if !strings.HasSuffix(resp.FileName, "."+format.Extension()) {
+				t.Errorf("FileName = %q, want extension %q", resp.FileName, format.Extension())
+			}
+		})
+	}
+}
@@ -3,16 +3,16 @@ package to_image
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"golang.org/x/image/draw"
 	"image"
-	"image/jpeg"
 	"log"
-	"main/to_pdf"
+	"main/card_error"
+	"main/card_image"
+	"main/fetcher"
 	"sync"
 	"time"
 
-	"github.com/gen2brain/go-fitz" // Replace unipdf with go-fitz
 	"main/data"
 )
 
@@ -27,209 +27,346 @@ var bufPool = sync.Pool{
 type GenerateImageResponse struct {
 	ImageContent []byte
 	FileName     string
+	ContentType  string
+	// FailedCards lists the cards left out of a lenient merge, in card order
+	FailedCards []*card_error.CardError
 }
 
+// cardJob is a card to decode together with its position in the response
+type cardJob struct {
+	index int
+	card  data.IdCard
+}
+
+// Options controls how the merged image is generated
+type Options struct {
+	// Layout arranges the card faces; LayoutStacked is used when empty
+	Layout Layout
+	// Columns is the number of faces per row of LayoutGrid; the grid is made
+	// as square as possible when 0
+	Columns int
+	// Format is the output encoding; DefaultFormat is used when empty
+	Format Format
+	// Quality is the JPEG quality from 1 to 100; DefaultJPEGQuality is used when 0
+	Quality int
+	// Timeouts bounds each stage; zero fields fall back to DefaultTimeouts
+	Timeouts Timeouts
+	// Fetcher downloads remote card images; fetcher.Default() is used when nil
+	Fetcher *fetcher.Fetcher
+	// HTMLRasterizer renders HTML cards; DefaultHTMLRasterizer() is used when nil
+	HTMLRasterizer HTMLRasterizer
+	// Geometry sets the card size, spacing, background and DPI of the merged
+	// image; DefaultLayoutOptions(DefaultDPI) is used when nil
+	Geometry *LayoutOptions
+	// Trim controls cutting the whitespace around rendered HTML cards
+	Trim TrimOptions
+	// Strict fails the whole merge with a *card_error.CardsError when any card fails.
+	// Otherwise failed cards are left out and listed in FailedCards.
+	Strict bool
+	// Limits caps the size of decoded card images and of the merged image;
+	// zero fields fall back to card_image.DefaultLimits
+	Limits card_image.Limits
+	// Interpolator scales the cards into the merged image; DefaultInterpolator
+	// is used when empty
+	Interpolator Interpolator
+}
+
+// Timeouts bounds how long each stage of image generation may run
+type Timeouts struct {
+	// Fetch bounds downloading a single remote card image
+	Fetch time.Duration
+	// Render bounds rendering a single HTML card to an image
+	Render time.Duration
+}
+
+// DefaultTimeouts are used for stages without an explicit timeout
+var DefaultTimeouts = Timeouts{
+	Fetch:  10 * time.Second,
+	Render: 30 * time.Second,
+}
+
+// geometry returns the layout options, falling back to the defaults
+func (o Options) geometry() LayoutOptions {
+	if o.Geometry == nil {
+		return DefaultLayoutOptions(DefaultDPI)
+	}
+	return *o.Geometry
+}
+
+func (t Timeouts) withDefaults() Timeouts {
+	if t.Fetch <= 0 {
+		t.Fetch = DefaultTimeouts.Fetch
+	}
+	if t.Render <= 0 {
+		t.Render = DefaultTimeouts.Render
+	}
+	return t
+}
+
+// MergeImages merges all ID cards into a single image, stacking them in the
+// order they appear in idCardsResp.Data
 func MergeImages(ctx context.Context, idCardsResp data.IdCardsResponseSchema) (*GenerateImageResponse, error) {
-	var images []image.Image
-	var pdfCards []data.IdCard
+	return MergeImagesWithOptions(ctx, idCardsResp, Options{})
+}
+
+// MergeImagesWithOptions merges all ID cards into a single image using the given options
+func MergeImagesWithOptions(ctx context.Context, idCardsResp data.IdCardsResponseSchema, opts Options) (*GenerateImageResponse, error) {
+	geometry := opts.geometry()
+	if err := geometry.validate(); err != nil {
+		return nil, err
+	}
+	format := opts.Format
+	if format == "" {
+		format = DefaultFormat
+	}
+	if geometry.Transparent() && !format.HasAlpha() {
+		return nil, ErrTransparentFormat
+	}
+	// Checked for every card before any is fetched: cards that fail later only
+	// make the image smaller
+	if len(idCardsResp.Data) > 0 {
+		columns, rows := canvasGrid(opts.Layout, idCardsResp.Data, opts.Columns)
+		if err := geometry.CheckCanvas(columns, rows, opts.Limits); err != nil {
+			return nil, err
+		}
+	}
+
+	timeouts := opts.Timeouts.withDefaults()
+	imageFetcher := opts.Fetcher
+	if imageFetcher == nil {
+		imageFetcher = fetcher.Default()
+	}
+	budget := card_image.NewBudget(opts.Limits)
+
+	// One slot per card so the merged image follows the response order no
+	// matter which worker finishes first
+	slots := make([]image.Image, len(idCardsResp.Data))
+	cardErrs := make([]*card_error.CardError, len(idCardsResp.Data))
+	var htmlIndexes []int
+	var htmlCards []data.IdCard
 
 	// Use a fixed number of workers for card processing.
 	numWorkers := 10
-	cardCh := make(chan data.IdCard)
+	cardCh := make(chan cardJob)
 	var wg sync.WaitGroup
-	var mu sync.Mutex
 
 	// Worker function for processing image cards.
 	for i := 0; i < numWorkers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for card := range cardCh {
-				if card.Attributes.Type == data.IdCardAttributesTypeHTML {
-					mu.Lock()
-					pdfCards = append(pdfCards, card)
-					mu.Unlock()
+			for job := range cardCh {
+				if ctx.Err() != nil {
 					continue
 				}
 				var img image.Image
 				var err error
-				if isURL(card.Attributes.Source) {
-					img, err = loadImageFromURL(card.Attributes.Source)
+				if isURL(job.card.Attributes.Source) {
+					fetchCtx, cancel := context.WithTimeout(ctx, timeouts.Fetch)
+					img, err = loadImageFromURL(fetchCtx, imageFetcher, budget, job.card.Attributes.Source)
+					cancel()
 				} else {
-					img, err = loadImageFromBase64(card.Attributes.Source)
+					img, err = loadImageFromSource(budget, job.card.Attributes.Source)
 				}
 				if err != nil {
 					log.Printf("Failed to load image: %v", err)
+					cardErrs[job.index] = asCardError(job.card, card_error.StageDecode, err)
 					continue
 				}
-				mu.Lock()
-				images = append(images, img)
-				mu.Unlock()
+				slots[job.index] = img
 			}
 		}()
 	}
 
-	for _, card := range idCardsResp.Data {
-		cardCh <- card
+	for i, card := range idCardsResp.Data {
+		if card.Attributes.Type == data.IdCardAttributesTypeHTML {
+			htmlIndexes = append(htmlIndexes, i)
+			htmlCards = append(htmlCards, card)
+			continue
+		}
+		select {
+		case cardCh <- cardJob{index: i, card: card}:
+		case <-ctx.Done():
+		}
 	}
 	close(cardCh)
 	wg.Wait()
 
-	if len(pdfCards) > 0 {
-		htmlImages, err := ConvertHTMLCardsToImage(ctx, pdfCards)
-		if err != nil {
-			log.Printf("Warning: Failed to convert HTML cards: %v", err)
-		} else {
-			images = append(images, htmlImages...)
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("image generation aborted: %w", err)
+	}
+	// Going over the request budget fails the request even when lenient, as
+	// leaving out the cards that happened to be decoded last is arbitrary
+	for _, cardErr := range cardErrs {
+		if cardErr != nil && errors.Is(cardErr, card_image.ErrRequestLimit) {
+			return nil, fmt.Errorf("image generation aborted: %w", cardErr.Err)
 		}
 	}
 
-	if len(images) == 0 {
-		return nil, fmt.Errorf("no valid images found to merge")
+	if len(htmlCards) > 0 {
+		htmlImages, htmlErrs := convertHTMLCards(ctx, htmlCards, opts)
+		for i, img := range htmlImages {
+			slots[htmlIndexes[i]] = img
+			cardErrs[htmlIndexes[i]] = htmlErrs[i]
+		}
 	}
 
-	mergedImg, err := mergeImagesVertically(images)
-	if err != nil {
-		return nil, fmt.Errorf("failed to merge images: %w", err)
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("image generation aborted: %w", err)
+	}
+
+	var images []image.Image
+	var imageCards []data.IdCard
+	for i, img := range slots {
+		if img != nil {
+			images = append(images, img)
+			imageCards = append(imageCards, idCardsResp.Data[i])
+		}
+	}
+
+	var failed []*card_error.CardError
+	for _, cardErr := range cardErrs {
+		if cardErr != nil {
+			failed = append(failed, cardErr)
+		}
+	}
+	if len(images) == 0 || (opts.Strict && len(failed) > 0) {
+		if len(failed) == 0 {
+			return nil, fmt.Errorf("no valid images found to merge")
+		}
+		return nil, &card_error.CardsError{Cards: failed, Total: len(idCardsResp.Data)}
 	}
 
+	quality := opts.Quality
+	if quality == 0 {
+		quality = DefaultJPEGQuality
+	}
+	kernel := opts.Interpolator.kernel()
+
 	// Get a buffer from the pool
 	buf := bufPool.Get().(*bytes.Buffer)
 	defer bufPool.Put(buf)
 	buf.Reset()
 
-	// Use JPEG encoding for lower memory footprint
-	if err := jpeg.Encode(buf, mergedImg, &jpeg.Options{Quality: 90}); err != nil {
+	if opts.Layout == LayoutZip {
+		if err := writeZip(buf, images, imageCards, geometry, kernel, format, quality); err != nil {
+			return nil, fmt.Errorf("failed to write ZIP archive: %w", err)
+		}
+		return &GenerateImageResponse{
+			ImageContent: bytes.Clone(buf.Bytes()),
+			FileName:     fmt.Sprintf("id_cards_%s.zip", time.Now().Format("20060102_150405")),
+			ContentType:  ZipContentType,
+			FailedCards:  failed,
+		}, nil
+	}
+
+	var mergedImg image.Image
+	var err error
+	switch opts.Layout {
+	case LayoutPaired:
+		mergedImg, err = mergeImagesPaired(slots, data.PairIdCards(idCardsResp.Data), geometry, kernel)
+	case LayoutGrid:
+		mergedImg, err = mergeImagesGrid(images, gridColumns(len(images), opts.Columns), geometry, kernel)
+	case LayoutHorizontal:
+		mergedImg, err = mergeImagesGrid(images, len(images), geometry, kernel)
+	default:
+		mergedImg, err = mergeImagesGrid(images, 1, geometry, kernel)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge images: %w", err)
+	}
+
+	if err := encodeImage(buf, mergedImg, format, quality); err != nil {
 		return nil, fmt.Errorf("failed to encode merged image: %w", err)
 	}
 
-	fileName := fmt.Sprintf("id_cards_%s.jpg", time.Now().Format("20060102_150405"))
+	fileName := fmt.Sprintf("id_cards_%s.%s", time.Now().Format("20060102_150405"), format.Extension())
 	return &GenerateImageResponse{
-		ImageContent: buf.Bytes(),
+		// Copy out of the pooled buffer, which is reused once it is put back
+		ImageContent: bytes.Clone(buf.Bytes()),
 		FileName:     fileName,
+		ContentType:  format.ContentType(),
+		FailedCards:  failed,
 	}, nil
 }
 
-// Updated to use standard library draw package for better performance
-func mergeImagesVertically(images []image.Image) (image.Image, error) {
-	if len(images) == 0 {
-		return nil, fmt.Errorf("no images to merge")
+// ConvertHTMLCardsToImage renders each HTML card to an image. The returned slice
+// is aligned with htmlCards; cards that fail to render are left nil and
+// reported in the returned *card_error.CardsError.
+func ConvertHTMLCardsToImage(ctx context.Context, htmlCards []data.IdCard) ([]image.Image, error) {
+	images, cardErrs := convertHTMLCards(ctx, htmlCards, Options{})
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("HTML card rendering aborted: %w", err)
 	}
 
-	// Calculate dimensions for the composite image
-	cardWidth := 1012
-	cardHeight := 638
-	margin := 30
-	sideMargin := 60
-
-	totalHeight := (cardHeight * len(images)) + (margin * (len(images) - 1)) + (sideMargin * 2)
-	totalWidth := cardWidth + (sideMargin * 2)
-
-	// Create the destination image
-	mergedImg := image.NewRGBA(image.Rect(0, 0, totalWidth, totalHeight))
-
-	// Fill with white background
-	draw.Draw(mergedImg, mergedImg.Bounds(), image.White, image.Point{}, draw.Src)
-
-	currentY := sideMargin
-	for _, img := range images {
-		bounds := img.Bounds()
-		origWidth := bounds.Dx()
-		origHeight := bounds.Dy()
-
-		// Calculate scaling
-		widthRatio := float64(cardWidth) / float64(origWidth)
-		heightRatio := float64(cardHeight) / float64(origHeight)
-		ratio := widthRatio
-		if heightRatio < widthRatio {
-			ratio = heightRatio
+	var failed []*card_error.CardError
+	for _, cardErr := range cardErrs {
+		if cardErr != nil {
+			failed = append(failed, cardErr)
 		}
-
-		newWidth := int(float64(origWidth) * ratio)
-		newHeight := int(float64(origHeight) * ratio)
-
-		// Create appropriately sized rectangle
-		xPos := sideMargin + ((cardWidth - newWidth) / 2)
-		yPos := currentY + ((cardHeight - newHeight) / 2)
-
-		// Use standard library's draw - more efficient than imgconv
-		draw.NearestNeighbor.Scale(
-			mergedImg,
-			image.Rect(xPos, yPos, xPos+newWidth, yPos+newHeight),
-			img,
-			img.Bounds(),
-			draw.Over,
-			nil,
-		)
-
-		currentY += cardHeight + margin
 	}
-	return mergedImg, nil
+	if len(failed) > 0 {
+		return images, &card_error.CardsError{Cards: failed, Total: len(htmlCards)}
+	}
+	return images, nil
 }
 
-func ConvertHTMLCardsToImage(ctx context.Context, htmlCards []data.IdCard) ([]image.Image, error) {
+// convertHTMLCards renders the HTML cards with the rasterizer, DPI, trimming
+// and render timeout of opts. Both returned slices are aligned with htmlCards.
+func convertHTMLCards(ctx context.Context, htmlCards []data.IdCard, opts Options) ([]image.Image, []*card_error.CardError) {
+	rasterizer := opts.HTMLRasterizer
+	if rasterizer == nil {
+		rasterizer = DefaultHTMLRasterizer()
+	}
+	dpi := opts.geometry().DPI
+	timeout := opts.Timeouts.withDefaults().Render
+
 	numWorkers := 4
-	cardCh := make(chan data.IdCard, len(htmlCards))
-	imgCh := make(chan image.Image, len(htmlCards))
-	errCh := make(chan error, len(htmlCards))
+	cardCh := make(chan cardJob, len(htmlCards))
+	images := make([]image.Image, len(htmlCards))
+	cardErrs := make([]*card_error.CardError, len(htmlCards))
 
 	var wg sync.WaitGroup
 	for i := 0; i < numWorkers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for card := range cardCh {
-				idCardsResp := data.IdCardsResponseSchema{Data: []data.IdCard{card}}
-				pdfResponse, err := to_pdf.GeneratePDFFromIDCards(ctx, idCardsResp)
-				if err != nil {
-					errCh <- fmt.Errorf("failed to generate PDF from HTML card: %w", err)
+			for job := range cardCh {
+				if ctx.Err() != nil {
 					continue
 				}
-				img, err := convertPDFToImage(pdfResponse.PDFContent)
+				img, err := renderHTMLCard(ctx, rasterizer, job.card, dpi, timeout)
 				if err != nil {
-					errCh <- fmt.Errorf("failed to convert PDF to image: %w", err)
+					log.Printf("Warning: %v", err)
+					cardErrs[job.index] = asCardError(job.card, card_error.StageRender, err)
 					continue
 				}
-				imgCh <- img
+				images[job.index] = trimImage(img, opts.Trim)
 			}
 		}()
 	}
 
-	for _, card := range htmlCards {
-		cardCh <- card
+	for i, card := range htmlCards {
+		cardCh <- cardJob{index: i, card: card}
 	}
 	close(cardCh)
 	wg.Wait()
-	close(imgCh)
-	close(errCh)
-
-	var images []image.Image
-	for img := range imgCh {
-		images = append(images, img)
-	}
-
-	for err := range errCh {
-		log.Printf("Warning: %v\n", err)
-	}
-	return images, nil
+	return images, cardErrs
 }
 
-// Replace unipdf with go-fitz (MuPDF) for PDF to image conversion
-func convertPDFToImage(pdfBytes []byte) (image.Image, error) {
-	log.Printf("Converting PDF to image using go-fitz library")
+// renderHTMLCard renders a single HTML card to an image within timeout
+func renderHTMLCard(ctx context.Context, rasterizer HTMLRasterizer, card data.IdCard, dpi float64, timeout time.Duration) (image.Image, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	// Use NewFromMemory to avoid filesystem I/O
-	doc, err := fitz.NewFromMemory(pdfBytes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open PDF: %w", err)
-	}
-	defer doc.Close()
+	return rasterizer.Rasterize(ctx, card.Attributes.Source, dpi)
+}
 
-	// Convert the first page to an image
-	img, err := doc.Image(0)
-	if err != nil {
-		return nil, fmt.Errorf("failed to convert PDF page to image: %w", err)
+// asCardError attaches the card id to err, using stage when err carries none
+func asCardError(card data.IdCard, stage card_error.Stage, err error) *card_error.CardError {
+	var cardErr *card_error.CardError
+	if errors.As(err, &cardErr) {
+		return &card_error.CardError{CardId: card.Id, Stage: cardErr.Stage, Err: cardErr.Err}
 	}
-
-	return img, nil
+	return &card_error.CardError{CardId: card.Id, Stage: stage, Err: err}
 }
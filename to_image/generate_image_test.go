@@ -0,0 +1,222 @@
+package to_image
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"main/card_error"
+	"main/card_image"
+	"main/data"
+	"net/url"
+	"testing"
+
+	"github.com/sunshineplan/imgconv"
+)
+
+// solidCard returns a base64 card whose image is a single colour
+func solidCard(id string, face data.IdCardAttributesFace, c color.Color, width, height int) data.IdCard {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		panic(err)
+	}
+	return data.IdCard{
+		Id:   id,
+		Type: data.IdCardTypeIdCard,
+		Attributes: data.IdCardAttributes{
+			Type:   data.IdCardAttributesTypeBase64,
+			Face:   face,
+			Source: base64.StdEncoding.EncodeToString(buf.Bytes()),
+		},
+	}
+}
+
+func TestMergeImagesPreservesOrder(t *testing.T) {
+	colors := []color.RGBA{
+		{R: 255, A: 255},
+		{G: 255, A: 255},
+		{B: 255, A: 255},
+		{R: 255, G: 255, A: 255},
+		{A: 255},
+	}
+
+	// The first card is much larger so it finishes decoding last
+	var cards []data.IdCard
+	for i, c := range colors {
+		width, height := 200, 126
+		if i == 0 {
+			width, height = 4000, 2520
+		}
+		cards = append(cards, solidCard(fmt.Sprintf("card-%d", i), data.IdCardAttributesFaceFront, c, width, height))
+	}
+
+	for run := 0; run < 3; run++ {
+		resp, err := MergeImages(context.Background(), data.IdCardsResponseSchema{Data: cards})
+		if err != nil {
+			t.Fatalf("MergeImages() error = %v", err)
+		}
+
+		merged, err := imgconv.Decode(bytes.NewReader(resp.ImageContent))
+		if err != nil {
+			t.Fatalf("failed to decode merged image: %v", err)
+		}
+
+		for i, want := range colors {
+			// Centre of the i-th card slot, see mergeImagesVertically
+			x := 60 + 1012/2
+			y := 60 + i*(638+30) + 638/2
+			r, g, b, _ := merged.At(x, y).RGBA()
+			got := color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 255}
+			if !closeTo(got, want) {
+				t.Fatalf("run %d: card %d has colour %v, want %v", run, i, got, want)
+			}
+		}
+	}
+}
+
+func closeTo(a, b color.RGBA) bool {
+	diff := func(x, y uint8) int {
+		if x > y {
+			return int(x - y)
+		}
+		return int(y - x)
+	}
+	return diff(a.R, b.R) < 16 && diff(a.G, b.G) < 16 && diff(a.B, b.B) < 16
+}
+
+func TestMergeImagesPairedLayout(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	cards := []data.IdCard{
+		solidCard("medical-back", data.IdCardAttributesFaceBack, blue, 200, 126),
+		solidCard("medical-front", data.IdCardAttributesFaceFront, red, 200, 126),
+	}
+
+	resp, err := MergeImagesWithOptions(context.Background(), data.IdCardsResponseSchema{Data: cards}, Options{Layout: LayoutPaired})
+	if err != nil {
+		t.Fatalf("MergeImagesWithOptions() error = %v", err)
+	}
+
+	merged, err := imgconv.Decode(bytes.NewReader(resp.ImageContent))
+	if err != nil {
+		t.Fatalf("failed to decode merged image: %v", err)
+	}
+
+	wantBounds := image.Rect(0, 0, 2*cardWidth+margin+2*sideMargin, cardHeight+2*sideMargin)
+	if merged.Bounds() != wantBounds {
+		t.Fatalf("merged bounds = %v, want %v", merged.Bounds(), wantBounds)
+	}
+
+	y := sideMargin + cardHeight/2
+	for _, tt := range []struct {
+		x    int
+		want color.RGBA
+	}{
+		{x: sideMargin + cardWidth/2, want: red},
+		{x: sideMargin + cardWidth + margin + cardWidth/2, want: blue},
+	} {
+		r, g, b, _ := merged.At(tt.x, y).RGBA()
+		got := color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 255}
+		if !closeTo(got, tt.want) {
+			t.Errorf("colour at x=%d = %v, want %v", tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestMergeImagesReportsFailedCards(t *testing.T) {
+	broken := data.MockImageIdCardBack
+	broken.Id = "broken-card"
+	broken.Attributes.Source = "not base64!"
+	cards := data.IdCardsResponseSchema{
+		Data: []data.IdCard{
+			solidCard("good-card", data.IdCardAttributesFaceFront, color.White, 200, 126),
+			broken,
+		},
+	}
+
+	t.Run("lenient", func(t *testing.T) {
+		resp, err := MergeImagesWithOptions(context.Background(), cards, Options{})
+		if err != nil {
+			t.Fatalf("MergeImagesWithOptions() error = %v", err)
+		}
+		if len(resp.FailedCards) != 1 {
+			t.Fatalf("FailedCards = %v, want 1 entry", resp.FailedCards)
+		}
+		if got := resp.FailedCards[0]; got.CardId != "broken-card" || got.Stage != card_error.StageDecode {
+			t.Errorf("FailedCards[0] = %+v", got)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		_, err := MergeImagesWithOptions(context.Background(), cards, Options{Strict: true})
+		var cardsErr *card_error.CardsError
+		if !errors.As(err, &cardsErr) {
+			t.Fatalf("MergeImagesWithOptions() error = %v, want *card_error.CardsError", err)
+		}
+		if len(cardsErr.Cards) != 1 || cardsErr.Cards[0].CardId != "broken-card" || cardsErr.Total != 2 {
+			t.Errorf("card_error.CardsError = %+v", cardsErr)
+		}
+	})
+}
+
+func TestMergeImagesDecodesSVGAndDataURIs(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	green := color.RGBA{G: 255, A: 255}
+	svg := solidCard("svg-card", data.IdCardAttributesFaceFront, red, 1, 1)
+	svg.Attributes.Source = "data:image/svg+xml," + url.PathEscape(
+		`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 200 126"><rect width="200" height="126" fill="#ff0000"/></svg>`)
+	dataURI := solidCard("png-card", data.IdCardAttributesFaceBack, green, 200, 126)
+	dataURI.Attributes.Source = "data:image/jpeg;base64," + dataURI.Attributes.Source
+
+	resp, err := MergeImagesWithOptions(context.Background(), data.IdCardsResponseSchema{Data: []data.IdCard{svg, dataURI}}, Options{Strict: true})
+	if err != nil {
+		t.Fatalf("MergeImagesWithOptions() error = %v", err)
+	}
+	merged, err := imgconv.Decode(bytes.NewReader(resp.ImageContent))
+	if err != nil {
+		t.Fatalf("failed to decode merged image: %v", err)
+	}
+	for i, want := range []color.RGBA{red, green} {
+		x := 60 + 1012/2
+		y := 60 + i*(638+30) + 638/2
+		r, g, b, _ := merged.At(x, y).RGBA()
+		got := color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 255}
+		if !closeTo(got, want) {
+			t.Errorf("card %d has colour %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestMergeImagesLimits(t *testing.T) {
+	cards := data.IdCardsResponseSchema{Data: []data.IdCard{
+		solidCard("small-card", data.IdCardAttributesFaceFront, color.White, 200, 126),
+		solidCard("large-card", data.IdCardAttributesFaceBack, color.White, 400, 252),
+	}}
+
+	t.Run("card", func(t *testing.T) {
+		resp, err := MergeImagesWithOptions(context.Background(), cards, Options{Limits: card_image.Limits{MaxCardPixels: 200 * 126}})
+		if err != nil {
+			t.Fatalf("MergeImagesWithOptions() error = %v", err)
+		}
+		if len(resp.FailedCards) != 1 || resp.FailedCards[0].CardId != "large-card" || !errors.Is(resp.FailedCards[0], card_image.ErrCardLimit) {
+			t.Errorf("FailedCards = %v, want large-card over the card limit", resp.FailedCards)
+		}
+	})
+
+	t.Run("request", func(t *testing.T) {
+		_, err := MergeImagesWithOptions(context.Background(), cards, Options{Limits: card_image.Limits{MaxRequestPixels: 400 * 252}})
+		if !errors.Is(err, card_image.ErrRequestLimit) {
+			t.Errorf("MergeImagesWithOptions() error = %v, want ErrRequestLimit", err)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package to_image
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"main/card_image"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Geometry of the merged image at DefaultDPI, in pixels
+const (
+	cardWidth  = 1012
+	cardHeight = 638
+	margin     = 30
+	sideMargin = 60
+)
+
+// ErrTransparentFormat is returned when a transparent background is asked for
+// in a format without an alpha channel
+var ErrTransparentFormat = errors.New("transparent backgrounds need the png, webp or tiff format")
+
+// LayoutOptions sets the geometry of the merged image. Lengths are in pixels
+// of the merged image.
+type LayoutOptions struct {
+	// DPI is the resolution the cards are laid out and HTML cards rendered at
+	DPI float64
+	// CardWidth and CardHeight are the size of the slot each card face is
+	// scaled into, keeping its aspect ratio
+	CardWidth, CardHeight int
+	// Gutter is the space between cards
+	Gutter int
+	// Padding is the space between the cards and the edge of the image
+	Padding int
+	// Background fills the image around the cards; white when nil. Colours
+	// that are not opaque need a format with alpha, see Format.HasAlpha
+	Background color.Color
+	// CornerRadius rounds the corners of every card, showing the background
+	// behind them; the corners are square when 0
+	CornerRadius int
+}
+
+// DefaultLayoutOptions returns the default geometry at dpi: CR80 card slots
+// with a 2.54 mm gutter and 5.08 mm padding on white, 1012 pixels per card at
+// DefaultDPI
+func DefaultLayoutOptions(dpi float64) LayoutOptions {
+	scale := func(v int) int {
+		return int(math.Round(float64(v) * dpi / DefaultDPI))
+	}
+	return LayoutOptions{
+		DPI:        dpi,
+		CardWidth:  scale(cardWidth),
+		CardHeight: scale(cardHeight),
+		Gutter:     scale(margin),
+		Padding:    scale(sideMargin),
+		Background: color.White,
+	}
+}
+
+// validate reports geometry that cannot be drawn
+func (l LayoutOptions) validate() error {
+	switch {
+	case l.DPI <= 0:
+		return fmt.Errorf("invalid layout: DPI must be positive")
+	case l.CardWidth <= 0 || l.CardHeight <= 0:
+		return fmt.Errorf("invalid layout: card size must be positive")
+	case l.Gutter < 0 || l.Padding < 0 || l.CornerRadius < 0:
+		return fmt.Errorf("invalid layout: gutter, padding and corner radius cannot be negative")
+	}
+	return nil
+}
+
+// canvasSize returns the size of an image holding rows of columns card slots
+func (l LayoutOptions) canvasSize(columns, rows int) (width, height int64) {
+	width = int64(l.CardWidth)*int64(columns) + int64(l.Gutter)*int64(columns-1) + int64(l.Padding)*2
+	height = int64(l.CardHeight)*int64(rows) + int64(l.Gutter)*int64(rows-1) + int64(l.Padding)*2
+	return width, height
+}
+
+// CheckCanvas fails with card_image.ErrCanvasLimit when rows of columns card
+// slots make an image bigger than limits.MaxCanvasPixels
+func (l LayoutOptions) CheckCanvas(columns, rows int, limits card_image.Limits) error {
+	return limits.CheckCanvas(l.canvasSize(columns, rows))
+}
+
+// Transparent reports whether the background lets what is behind the image
+// show through
+func (l LayoutOptions) Transparent() bool {
+	if l.Background == nil {
+		return false
+	}
+	_, _, _, a := l.Background.RGBA()
+	return a != 0xffff
+}
+
+// newCanvas returns an image of width × height filled with the background
+func (l LayoutOptions) newCanvas(width, height int) *image.RGBA {
+	bg := l.Background
+	if bg == nil {
+		bg = color.White
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	return canvas
+}
+
+// ParseColor parses a background colour: "transparent" or a hex colour
+// written #rgb, #rrggbb or #rrggbbaa, with or without the #
+func ParseColor(s string) (color.Color, error) {
+	if strings.EqualFold(s, "transparent") {
+		return color.Transparent, nil
+	}
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 8 || err != nil {
+		return nil, fmt.Errorf("invalid colour %q", s)
+	}
+	// Straight alpha as written in CSS, stored premultiplied
+	c := color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}
+	return color.RGBAModel.Convert(c), nil
+}
+
+// roundedRect is a clip mask that is opaque inside a rectangle with rounded
+// corners, with antialiased edges
+type roundedRect struct {
+	rect   image.Rectangle
+	radius float64
+}
+
+func newRoundedRect(rect image.Rectangle, radius int) roundedRect {
+	// The radius cannot be more than half the shorter side
+	limit := min(rect.Dx(), rect.Dy()) / 2
+	return roundedRect{rect: rect, radius: float64(min(radius, limit))}
+}
+
+func (m roundedRect) ColorModel() color.Model { return color.AlphaModel }
+
+func (m roundedRect) Bounds() image.Rectangle { return m.rect }
+
+func (m roundedRect) At(x, y int) color.Color {
+	if !(image.Point{X: x, Y: y}.In(m.rect)) {
+		return color.Transparent
+	}
+	// Distance of the pixel centre from the centre of the nearest corner arc,
+	// on the axes where the pixel lies beyond the arc's centre
+	px, py := float64(x)+0.5, float64(y)+0.5
+	dx := math.Max(0, math.Max(float64(m.rect.Min.X)+m.radius-px, px-(float64(m.rect.Max.X)-m.radius)))
+	dy := math.Max(0, math.Max(float64(m.rect.Min.Y)+m.radius-py, py-(float64(m.rect.Max.Y)-m.radius)))
+	if dx == 0 || dy == 0 {
+		return color.Opaque
+	}
+	coverage := m.radius + 0.5 - math.Hypot(dx, dy)
+	return color.Alpha{A: uint8(math.Round(255 * math.Max(0, math.Min(1, coverage))))}
+}
@@ -0,0 +1,156 @@
+package to_image
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"main/card_image"
+	"main/data"
+	"testing"
+)
+
+func TestDefaultLayoutOptions(t *testing.T) {
+	tests := []struct {
+		dpi  float64
+		want LayoutOptions
+	}{
+		{DefaultDPI, LayoutOptions{DPI: DefaultDPI, CardWidth: cardWidth, CardHeight: cardHeight, Gutter: margin, Padding: sideMargin, Background: color.White}},
+		{150, LayoutOptions{DPI: 150, CardWidth: 506, CardHeight: 319, Gutter: 15, Padding: 30, Background: color.White}},
+	}
+	for _, tt := range tests {
+		if got := DefaultLayoutOptions(tt.dpi); got != tt.want {
+			t.Errorf("DefaultLayoutOptions(%v) = %+v, want %+v", tt.dpi, got, tt.want)
+		}
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want color.RGBA
+	}{
+		{"transparent", color.RGBA{}},
+		{"#ffffff", color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+		{"1a2B3c", color.RGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 255}},
+		{"#f00", color.RGBA{R: 255, A: 255}},
+		{"#ff000080", color.RGBA{R: 128, A: 128}},
+	}
+	for _, tt := range tests {
+		got, err := ParseColor(tt.in)
+		if err != nil {
+			t.Errorf("ParseColor(%q) error = %v", tt.in, err)
+			continue
+		}
+		if c := color.RGBAModel.Convert(got); c != tt.want {
+			t.Errorf("ParseColor(%q) = %v, want %v", tt.in, c, tt.want)
+		}
+	}
+	for _, in := range []string{"", "#ff", "white", "#gggggg", "#ff0000ff00"} {
+		if _, err := ParseColor(in); err == nil {
+			t.Errorf("ParseColor(%q) accepted an invalid colour", in)
+		}
+	}
+}
+
+func TestMergeImagesGeometry(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	cards := data.IdCardsResponseSchema{Data: []data.IdCard{
+		solidCard("medical", data.IdCardAttributesFaceFront, red, 200, 100),
+		solidCard("dental", data.IdCardAttributesFaceFront, red, 200, 100),
+	}}
+	geometry := LayoutOptions{
+		DPI:          DefaultDPI,
+		CardWidth:    400,
+		CardHeight:   200,
+		Gutter:       10,
+		Padding:      20,
+		Background:   color.Transparent,
+		CornerRadius: 40,
+	}
+
+	resp, err := MergeImagesWithOptions(context.Background(), cards, Options{Format: FormatPNG, Geometry: &geometry})
+	if err != nil {
+		t.Fatalf("MergeImagesWithOptions() error = %v", err)
+	}
+	merged, err := png.Decode(bytes.NewReader(resp.ImageContent))
+	if err != nil {
+		t.Fatalf("failed to decode merged image: %v", err)
+	}
+
+	if want := image.Rect(0, 0, 440, 450); merged.Bounds() != want {
+		t.Fatalf("merged bounds = %v, want %v", merged.Bounds(), want)
+	}
+	for _, tt := range []struct {
+		name   string
+		x, y   int
+		opaque bool
+	}{
+		{"padding", 5, 5, false},
+		{"rounded corner", 21, 21, false},
+		{"gutter", 220, 225, false},
+		{"card centre", 220, 120, true},
+		{"card edge", 20, 120, true},
+		{"second card", 220, 330, true},
+	} {
+		_, _, _, a := merged.At(tt.x, tt.y).RGBA()
+		if opaque := a == 0xffff; opaque != tt.opaque || (!opaque && a != 0) {
+			t.Errorf("%s at (%d, %d) has alpha %#x, want opaque = %v", tt.name, tt.x, tt.y, a, tt.opaque)
+		}
+	}
+}
+
+func TestMergeImagesGeometryErrors(t *testing.T) {
+	cards := data.IdCardsResponseSchema{Data: []data.IdCard{
+		solidCard("medical", data.IdCardAttributesFaceFront, color.Black, 200, 100),
+	}}
+
+	transparent := DefaultLayoutOptions(DefaultDPI)
+	transparent.Background = color.Transparent
+	if _, err := MergeImagesWithOptions(context.Background(), cards, Options{Format: FormatJPEG, Geometry: &transparent}); !errors.Is(err, ErrTransparentFormat) {
+		t.Errorf("transparent JPEG error = %v, want ErrTransparentFormat", err)
+	}
+
+	empty := DefaultLayoutOptions(DefaultDPI)
+	empty.CardHeight = 0
+	if _, err := MergeImagesWithOptions(context.Background(), cards, Options{Geometry: &empty}); err == nil {
+		t.Error("MergeImagesWithOptions() accepted an empty card slot")
+	}
+}
+
+func TestMergeImagesCanvasLimit(t *testing.T) {
+	geometry := LayoutOptions{DPI: DefaultDPI, CardWidth: 100, CardHeight: 50, Gutter: 10, Padding: 5}
+	// Five stacked cards make a 110 × 300 image, paired ones 220 × 300
+	limits := card_image.Limits{MaxCanvasPixels: 110 * 300}
+
+	tests := []struct {
+		layout Layout
+		ok     bool
+	}{
+		{LayoutStacked, true},
+		{LayoutPaired, false},
+		{LayoutZip, true},
+	}
+	for _, tt := range tests {
+		_, err := MergeImagesWithOptions(context.Background(), paletteCards(), Options{
+			Layout:   tt.layout,
+			Format:   FormatPNG,
+			Geometry: &geometry,
+			Limits:   limits,
+		})
+		if tt.ok && err != nil {
+			t.Errorf("%s: MergeImagesWithOptions() error = %v", tt.layout, err)
+		}
+		if !tt.ok && !errors.Is(err, card_image.ErrCanvasLimit) {
+			t.Errorf("%s: MergeImagesWithOptions() error = %v, want ErrCanvasLimit", tt.layout, err)
+		}
+	}
+
+	tall := geometry
+	tall.CardHeight = 60
+	if _, err := MergeImagesWithOptions(context.Background(), paletteCards(), Options{Format: FormatPNG, Geometry: &tall, Limits: limits}); !errors.Is(err, card_image.ErrCanvasLimit) {
+		t.Errorf("taller cards error = %v, want ErrCanvasLimit", err)
+	}
+}
@@ -1,11 +1,11 @@
 package to_image
 
 import (
-	"bytes"
-	"encoding/base64"
-	"github.com/sunshineplan/imgconv"
+	"context"
 	"image"
-	"net/http"
+	"main/card_error"
+	"main/card_image"
+	"main/fetcher"
 	"strings"
 )
 
@@ -14,21 +14,29 @@ func isURL(s string) bool {
 	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
 }
 
-func loadImageFromURL(url string) (image.Image, error) {
-	resp, err := http.Get(url)
+func loadImageFromURL(ctx context.Context, f *fetcher.Fetcher, budget *card_image.Budget, url string) (image.Image, error) {
+	result, err := f.Fetch(ctx, url)
 	if err != nil {
-		return nil, err
+		return nil, stageError(card_error.StageFetch, err)
 	}
-	defer resp.Body.Close()
 
-	return imgconv.Decode(resp.Body)
+	img, err := budget.Decode(result.Body)
+	if err != nil {
+		return nil, stageError(card_error.StageDecode, err)
+	}
+	return img, nil
 }
 
-func loadImageFromBase64(base64Str string) (image.Image, error) {
-	data, err := base64.StdEncoding.DecodeString(base64Str)
+// loadImageFromSource decodes a base64 or data URI card source
+func loadImageFromSource(budget *card_image.Budget, source string) (image.Image, error) {
+	content, _, err := card_image.DecodeSource(source)
 	if err != nil {
-		return nil, err
+		return nil, stageError(card_error.StageDecode, err)
 	}
 
-	return imgconv.Decode(bytes.NewReader(data))
+	img, err := budget.Decode(content)
+	if err != nil {
+		return nil, stageError(card_error.StageDecode, err)
+	}
+	return img, nil
 }
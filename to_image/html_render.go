@@ -0,0 +1,177 @@
+package to_image
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"main/browser_pool"
+	"main/card_error"
+	"main/data"
+	"main/sanitize"
+	"main/to_pdf"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/gen2brain/go-fitz"
+)
+
+// Names of the built-in HTML rasterizers
+const (
+	RasterizerScreenshot = "screenshot"
+	RasterizerPDF        = "pdf"
+)
+
+// DefaultDPI renders a CR80 card (85.60mm wide) at cardWidth pixels
+const DefaultDPI = 300
+
+// cssDPI is the resolution CSS pixels are defined at
+const cssDPI = 96
+
+// cardElementID is the id of the element wrapping the card markup in the
+// screenshot page
+const cardElementID = "id-card"
+
+// HTMLRasterizer renders the markup of a single HTML card to an image
+type HTMLRasterizer interface {
+	// Name returns the rasterizer name used for selection
+	Name() string
+	// Rasterize renders html at dpi. Rasterize errors are tagged with the
+	// stage that failed.
+	Rasterize(ctx context.Context, html string, dpi float64) (image.Image, error)
+}
+
+// GetHTMLRasterizer returns the built-in rasterizer with the given name
+func GetHTMLRasterizer(name string) (HTMLRasterizer, error) {
+	switch name {
+	case RasterizerScreenshot:
+		return ScreenshotRasterizer{}, nil
+	case RasterizerPDF:
+		return PDFRasterizer{}, nil
+	}
+	return nil, fmt.Errorf("unknown HTML rasterizer %q", name)
+}
+
+// DefaultHTMLRasterizer returns the rasterizer used when none is configured
+func DefaultHTMLRasterizer() HTMLRasterizer {
+	return ScreenshotRasterizer{}
+}
+
+// ScreenshotRasterizer captures the card's bounding box with headless Chrome,
+// so the image holds only the card and no page whitespace
+type ScreenshotRasterizer struct {
+	// Pool renders in a shared browser; a fresh Chrome is started per card when nil
+	Pool *browser_pool.Pool
+}
+
+func (ScreenshotRasterizer) Name() string {
+	return RasterizerScreenshot
+}
+
+func (r ScreenshotRasterizer) Rasterize(ctx context.Context, html string, dpi float64) (image.Image, error) {
+	run := chromedp.Run
+	if r.Pool != nil {
+		run = r.Pool.Run
+	} else {
+		var cancel context.CancelFunc
+		ctx, cancel = chromedp.NewContext(ctx)
+		defer cancel()
+	}
+
+	var shot []byte
+	err := run(ctx,
+		// Scale CSS pixels so the screenshot comes out at the requested DPI
+		emulation.SetDeviceMetricsOverride(cardWidth, cardHeight, dpi/cssDPI, false),
+		chromedp.Navigate("about:blank"),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			frameTree, err := page.GetFrameTree().Do(ctx)
+			if err != nil {
+				return err
+			}
+			return page.SetDocumentContent(frameTree.Frame.ID, buildCardPage(html)).Do(ctx)
+		}),
+		chromedp.Evaluate(to_pdf.WaitForImagesJS, nil, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		}),
+		chromedp.Screenshot("#"+cardElementID, &shot, chromedp.ByQuery),
+		// Pooled tabs are reused for PDFs, which must not inherit the scaling
+		emulation.ClearDeviceMetricsOverride(),
+	)
+	if err != nil {
+		return nil, stageError(card_error.StageRender, fmt.Errorf("failed to screenshot HTML card: %w", err))
+	}
+
+	img, err := png.Decode(bytes.NewReader(shot))
+	if err != nil {
+		return nil, stageError(card_error.StageRasterize, err)
+	}
+	return img, nil
+}
+
+// buildCardPage wraps the sanitised card markup in a page sized to the card,
+// so an element screenshot captures exactly the card
+func buildCardPage(html string) string {
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+ <style>
+  body { margin: 0; padding: 0; font-family: Arial, sans-serif; }
+  #%s { display: inline-block; }
+ </style>
+</head>
+<body><div id="%s">%s</div></body>
+</html>`, cardElementID, cardElementID, sanitize.HTML(html))
+}
+
+// PDFRasterizer renders the card to a PDF page and rasterises it with
+// go-fitz. It only needs a PDF engine, at the cost of a page-sized image.
+type PDFRasterizer struct {
+	// Renderer converts the card to PDF; wkhtmltopdf is used when nil, so
+	// HTML cards can be rendered without Chrome
+	Renderer to_pdf.Renderer
+}
+
+func (PDFRasterizer) Name() string {
+	return RasterizerPDF
+}
+
+func (r PDFRasterizer) Rasterize(ctx context.Context, html string, dpi float64) (image.Image, error) {
+	card := data.IdCard{Attributes: data.IdCardAttributes{Type: data.IdCardAttributesTypeHTML, Source: html}}
+	renderer := r.Renderer
+	if renderer == nil {
+		renderer = to_pdf.NewWkhtmltopdfRenderer()
+	}
+	pdfResponse, err := to_pdf.GeneratePDF(ctx, data.IdCardsResponseSchema{Data: []data.IdCard{card}}, to_pdf.Options{
+		Renderer: renderer,
+	})
+	if err != nil {
+		return nil, stageError(card_error.StageRender, fmt.Errorf("failed to generate PDF from HTML card: %w", err))
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, stageError(card_error.StageRasterize, err)
+	}
+	img, err := convertPDFToImage(pdfResponse.PDFContent, dpi)
+	if err != nil {
+		return nil, stageError(card_error.StageRasterize, err)
+	}
+	return img, nil
+}
+
+// convertPDFToImage rasterises the first page of the PDF at dpi with go-fitz (MuPDF)
+func convertPDFToImage(pdfBytes []byte, dpi float64) (image.Image, error) {
+	// Use NewFromMemory to avoid filesystem I/O
+	doc, err := fitz.NewFromMemory(pdfBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF: %w", err)
+	}
+	defer doc.Close()
+
+	img, err := doc.ImageDPI(0, dpi)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert PDF page to image: %w", err)
+	}
+	return img, nil
+}
@@ -0,0 +1,126 @@
+package to_image
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"main/card_error"
+	"main/data"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sunshineplan/imgconv"
+)
+
+// fakeRasterizer renders every card as a solid green image and records the
+// markup and DPI it was called with
+type fakeRasterizer struct {
+	mu   sync.Mutex
+	html []string
+	dpi  []float64
+	err  error
+}
+
+func (r *fakeRasterizer) Name() string {
+	return "fake"
+}
+
+func (r *fakeRasterizer) Rasterize(ctx context.Context, html string, dpi float64) (image.Image, error) {
+	r.mu.Lock()
+	r.html = append(r.html, html)
+	r.dpi = append(r.dpi, dpi)
+	r.mu.Unlock()
+	if r.err != nil {
+		return nil, stageError(card_error.StageRender, r.err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 320, 202))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{G: 255, A: 255}), image.Point{}, draw.Src)
+	return img, nil
+}
+
+func TestMergeImagesUsesHTMLRasterizer(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	green := color.RGBA{G: 255, A: 255}
+	cards := []data.IdCard{
+		data.MockHTMLIdCardFront,
+		solidCard("image-card", data.IdCardAttributesFaceBack, red, 200, 126),
+	}
+
+	rasterizer := &fakeRasterizer{}
+	geometry := DefaultLayoutOptions(150)
+	resp, err := MergeImagesWithOptions(context.Background(), data.IdCardsResponseSchema{Data: cards}, Options{
+		HTMLRasterizer: rasterizer,
+		Geometry:       &geometry,
+	})
+	if err != nil {
+		t.Fatalf("MergeImagesWithOptions() error = %v", err)
+	}
+
+	if len(rasterizer.html) != 1 || rasterizer.html[0] != data.MockHTMLIdCardFront.Attributes.Source {
+		t.Fatalf("rasterizer called with %d cards, want the HTML card", len(rasterizer.html))
+	}
+	if rasterizer.dpi[0] != 150 {
+		t.Errorf("rasterizer dpi = %v, want 150", rasterizer.dpi[0])
+	}
+
+	merged, err := imgconv.Decode(bytes.NewReader(resp.ImageContent))
+	if err != nil {
+		t.Fatalf("failed to decode merged image: %v", err)
+	}
+	for i, want := range []color.RGBA{green, red} {
+		x := geometry.Padding + geometry.CardWidth/2
+		y := geometry.Padding + i*(geometry.CardHeight+geometry.Gutter) + geometry.CardHeight/2
+		r, g, b, _ := merged.At(x, y).RGBA()
+		got := color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 255}
+		if !closeTo(got, want) {
+			t.Errorf("card %d has colour %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestMergeImagesHTMLRasterizerFailure(t *testing.T) {
+	cards := []data.IdCard{
+		data.MockHTMLIdCardFront,
+		solidCard("image-card", data.IdCardAttributesFaceBack, color.White, 200, 126),
+	}
+
+	rasterizer := &fakeRasterizer{err: errors.New("chrome not found")}
+	resp, err := MergeImagesWithOptions(context.Background(), data.IdCardsResponseSchema{Data: cards}, Options{
+		HTMLRasterizer: rasterizer,
+	})
+	if err != nil {
+		t.Fatalf("MergeImagesWithOptions() error = %v", err)
+	}
+	if len(resp.FailedCards) != 1 || resp.FailedCards[0].CardId != data.MockHTMLIdCardFront.Id || resp.FailedCards[0].Stage != card_error.StageRender {
+		t.Errorf("FailedCards = %v", resp.FailedCards)
+	}
+	if rasterizer.dpi[0] != DefaultDPI {
+		t.Errorf("rasterizer dpi = %v, want %v", rasterizer.dpi[0], DefaultDPI)
+	}
+}
+
+func TestGetHTMLRasterizer(t *testing.T) {
+	for _, name := range []string{RasterizerScreenshot, RasterizerPDF} {
+		r, err := GetHTMLRasterizer(name)
+		if err != nil {
+			t.Fatalf("GetHTMLRasterizer(%q) error = %v", name, err)
+		}
+		if r.Name() != name {
+			t.Errorf("GetHTMLRasterizer(%q).Name() = %q", name, r.Name())
+		}
+	}
+	if _, err := GetHTMLRasterizer("wkhtmltoimage"); err == nil {
+		t.Error("GetHTMLRasterizer() accepted an unknown rasterizer")
+	}
+}
+
+func TestBuildCardPage(t *testing.T) {
+	html := buildCardPage(`<p>Member</p>`)
+	if !strings.Contains(html, `<div id="`+cardElementID+`"><p>Member</p></div>`) {
+		t.Errorf("card markup is not wrapped in the screenshot element:\n%s", html)
+	}
+}
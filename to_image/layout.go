@@ -0,0 +1,162 @@
+package to_image
+
+import (
+	"fmt"
+	"golang.org/x/image/draw"
+	"image"
+	"main/data"
+	"math"
+)
+
+// Layout controls how card faces are arranged in the merged image
+type Layout string
+
+const (
+	// LayoutStacked puts every face below the previous one
+	LayoutStacked Layout = "stacked"
+	// LayoutPaired puts the front and back faces of a card side by side
+	LayoutPaired Layout = "paired"
+	// LayoutGrid fills rows of Options.Columns faces, left to right
+	LayoutGrid Layout = "grid"
+	// LayoutHorizontal puts every face right of the previous one
+	LayoutHorizontal Layout = "horizontal"
+	// LayoutZip writes one image per face into a ZIP archive
+	LayoutZip Layout = "zip"
+)
+
+// ParseLayout converts a layout name into a Layout, defaulting to LayoutStacked
+func ParseLayout(name string) (Layout, error) {
+	switch Layout(name) {
+	case "", LayoutStacked:
+		return LayoutStacked, nil
+	case LayoutPaired, LayoutGrid, LayoutHorizontal, LayoutZip:
+		return Layout(name), nil
+	}
+	return "", fmt.Errorf("unknown layout %q", name)
+}
+
+// gridColumns returns the number of columns of a grid of n faces, making the
+// grid as square as possible when columns is 0
+func gridColumns(n, columns int) int {
+	if columns <= 0 {
+		columns = int(math.Ceil(math.Sqrt(float64(n))))
+	}
+	return max(1, min(columns, n))
+}
+
+// canvasGrid returns the columns and rows of card slots the largest image of
+// layout has when all n cards are drawn. ZIP archives hold one card per image.
+func canvasGrid(layout Layout, cards []data.IdCard, columns int) (int, int) {
+	n := len(cards)
+	switch layout {
+	case LayoutPaired:
+		return 2, len(data.PairIdCards(cards))
+	case LayoutGrid:
+		columns = gridColumns(n, columns)
+		return columns, (n + columns - 1) / columns
+	case LayoutHorizontal:
+		return n, 1
+	case LayoutZip:
+		return 1, 1
+	}
+	return 1, n
+}
+
+// mergeImagesGrid draws the images left to right in rows of columns slots,
+// starting a new row below when one is full. One column stacks the images, as
+// many columns as images makes a horizontal strip.
+func mergeImagesGrid(images []image.Image, columns int, geometry LayoutOptions, kernel draw.Interpolator) (image.Image, error) {
+	if len(images) == 0 {
+		return nil, fmt.Errorf("no images to merge")
+	}
+
+	g := geometry
+	rows := (len(images) + columns - 1) / columns
+	totalWidth, totalHeight := g.canvasSize(columns, rows)
+
+	// Create the destination image filled with the background
+	mergedImg := g.newCanvas(int(totalWidth), int(totalHeight))
+
+	for i, img := range images {
+		x := g.Padding + (i%columns)*(g.CardWidth+g.Gutter)
+		y := g.Padding + (i/columns)*(g.CardHeight+g.Gutter)
+		drawFitted(mergedImg, img, image.Rect(x, y, x+g.CardWidth, y+g.CardHeight), g.CornerRadius, kernel)
+	}
+	return mergedImg, nil
+}
+
+// mergeImagesPaired draws one row per pair with the front on the left and the
+// back on the right. Combined faces span the whole row. slots holds the decoded
+// image of each card the pairs index into; rows without any image are skipped.
+func mergeImagesPaired(slots []image.Image, pairs []data.IdCardPair, geometry LayoutOptions, kernel draw.Interpolator) (image.Image, error) {
+	at := func(i int) image.Image {
+		if i == -1 {
+			return nil
+		}
+		return slots[i]
+	}
+
+	var rows []data.IdCardPair
+	for _, pair := range pairs {
+		if at(pair.Front) != nil || at(pair.Back) != nil || at(pair.Combined) != nil {
+			rows = append(rows, pair)
+		}
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no images to merge")
+	}
+
+	g := geometry
+	rowWidth := (g.CardWidth * 2) + g.Gutter
+	totalWidth, totalHeight := g.canvasSize(2, len(rows))
+
+	mergedImg := g.newCanvas(int(totalWidth), int(totalHeight))
+
+	currentY := g.Padding
+	for _, row := range rows {
+		if img := at(row.Combined); img != nil {
+			drawFitted(mergedImg, img, image.Rect(g.Padding, currentY, g.Padding+rowWidth, currentY+g.CardHeight), g.CornerRadius, kernel)
+		}
+		if img := at(row.Front); img != nil {
+			drawFitted(mergedImg, img, image.Rect(g.Padding, currentY, g.Padding+g.CardWidth, currentY+g.CardHeight), g.CornerRadius, kernel)
+		}
+		if img := at(row.Back); img != nil {
+			x := g.Padding + g.CardWidth + g.Gutter
+			drawFitted(mergedImg, img, image.Rect(x, currentY, x+g.CardWidth, currentY+g.CardHeight), g.CornerRadius, kernel)
+		}
+		currentY += g.CardHeight + g.Gutter
+	}
+	return mergedImg, nil
+}
+
+// drawFitted scales img with kernel to fit inside box, keeping its aspect
+// ratio, and draws it centred in the box. A positive radius rounds the corners
+// of the scaled image.
+func drawFitted(dst draw.Image, img image.Image, box image.Rectangle, radius int, kernel draw.Interpolator) {
+	bounds := img.Bounds()
+	origWidth := bounds.Dx()
+	origHeight := bounds.Dy()
+
+	// Calculate scaling
+	widthRatio := float64(box.Dx()) / float64(origWidth)
+	heightRatio := float64(box.Dy()) / float64(origHeight)
+	ratio := widthRatio
+	if heightRatio < widthRatio {
+		ratio = heightRatio
+	}
+
+	newWidth := int(float64(origWidth) * ratio)
+	newHeight := int(float64(origHeight) * ratio)
+
+	// Create appropriately sized rectangle
+	xPos := box.Min.X + ((box.Dx() - newWidth) / 2)
+	yPos := box.Min.Y + ((box.Dy() - newHeight) / 2)
+	rect := image.Rect(xPos, yPos, xPos+newWidth, yPos+newHeight)
+
+	var opts *draw.Options
+	if radius > 0 {
+		// The mask is in dst coordinates, so it clips the scaled card itself
+		opts = &draw.Options{DstMask: newRoundedRect(rect, radius)}
+	}
+	kernel.Scale(dst, rect, img, bounds, draw.Over, opts)
+}
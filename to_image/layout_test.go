@@ -0,0 +1,194 @@
+package to_image
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"main/card_image"
+	"main/data"
+	"testing"
+)
+
+func TestGridColumns(t *testing.T) {
+	tests := []struct {
+		n, columns, want int
+	}{
+		{1, 0, 1},
+		{4, 0, 2},
+		{5, 0, 3},
+		{7, 0, 3},
+		{10, 0, 4},
+		{7, 2, 2},
+		{3, 5, 3},
+	}
+	for _, tt := range tests {
+		if got := gridColumns(tt.n, tt.columns); got != tt.want {
+			t.Errorf("gridColumns(%d, %d) = %d, want %d", tt.n, tt.columns, got, tt.want)
+		}
+	}
+}
+
+// palette gives every card of the layout tests its own colour
+var palette = []color.RGBA{
+	{R: 255, A: 255},
+	{G: 255, A: 255},
+	{B: 255, A: 255},
+	{R: 255, G: 255, A: 255},
+	{G: 255, B: 255, A: 255},
+}
+
+func paletteCards() data.IdCardsResponseSchema {
+	var cards data.IdCardsResponseSchema
+	for i, c := range palette {
+		cards.Data = append(cards.Data, solidCard(string(rune('a'+i))+"-card", data.IdCardAttributesFaceFront, c, 200, 100))
+	}
+	return cards
+}
+
+func TestMergeImagesLayouts(t *testing.T) {
+	geometry := LayoutOptions{DPI: DefaultDPI, CardWidth: 100, CardHeight: 50, Gutter: 10, Padding: 5}
+
+	tests := []struct {
+		layout  Layout
+		columns int
+		// grid is the column and row of each card in palette order
+		grid [][2]int
+		want image.Rectangle
+	}{
+		{LayoutStacked, 0, [][2]int{{0, 0}, {0, 1}, {0, 2}, {0, 3}, {0, 4}}, image.Rect(0, 0, 110, 300)},
+		{LayoutHorizontal, 0, [][2]int{{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}}, image.Rect(0, 0, 550, 60)},
+		{LayoutGrid, 0, [][2]int{{0, 0}, {1, 0}, {2, 0}, {0, 1}, {1, 1}}, image.Rect(0, 0, 330, 120)},
+		{LayoutGrid, 2, [][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {0, 2}}, image.Rect(0, 0, 220, 180)},
+	}
+	for _, tt := range tests {
+		resp, err := MergeImagesWithOptions(context.Background(), paletteCards(), Options{
+			Layout:   tt.layout,
+			Columns:  tt.columns,
+			Format:   FormatPNG,
+			Geometry: &geometry,
+		})
+		if err != nil {
+			t.Fatalf("%s: MergeImagesWithOptions() error = %v", tt.layout, err)
+		}
+		merged, err := png.Decode(bytes.NewReader(resp.ImageContent))
+		if err != nil {
+			t.Fatalf("%s: failed to decode merged image: %v", tt.layout, err)
+		}
+		if merged.Bounds() != tt.want {
+			t.Errorf("%s/%d: merged bounds = %v, want %v", tt.layout, tt.columns, merged.Bounds(), tt.want)
+			continue
+		}
+
+		for i, cell := range tt.grid {
+			x := geometry.Padding + cell[0]*(geometry.CardWidth+geometry.Gutter) + geometry.CardWidth/2
+			y := geometry.Padding + cell[1]*(geometry.CardHeight+geometry.Gutter) + geometry.CardHeight/2
+			if got := color.RGBAModel.Convert(merged.At(x, y)).(color.RGBA); !closeTo(got, palette[i]) {
+				t.Errorf("%s/%d: card %d at (%d, %d) has colour %v, want %v", tt.layout, tt.columns, i, x, y, got, palette[i])
+			}
+		}
+	}
+}
+
+func TestMergeImagesLayoutCanvasLimit(t *testing.T) {
+	geometry := LayoutOptions{DPI: DefaultDPI, CardWidth: 100, CardHeight: 50, Gutter: 10, Padding: 5}
+
+	tests := []struct {
+		layout    Layout
+		columns   int
+		maxPixels int64
+		ok        bool
+	}{
+		// Five cards side by side make a 550 × 60 image
+		{LayoutHorizontal, 0, 550 * 60, true},
+		{LayoutHorizontal, 0, 550*60 - 1, false},
+		// Three columns of two rows make a 330 × 120 image
+		{LayoutGrid, 0, 330 * 120, true},
+		{LayoutGrid, 0, 330*120 - 1, false},
+		// Two columns of three rows make a 220 × 180 image
+		{LayoutGrid, 2, 220*180 - 1, false},
+		{LayoutGrid, 5, 550 * 60, true},
+	}
+	for _, tt := range tests {
+		_, err := MergeImagesWithOptions(context.Background(), paletteCards(), Options{
+			Layout:   tt.layout,
+			Columns:  tt.columns,
+			Format:   FormatPNG,
+			Geometry: &geometry,
+			Limits:   card_image.Limits{MaxCanvasPixels: tt.maxPixels},
+		})
+		if tt.ok && err != nil {
+			t.Errorf("%s/%d under %d pixels: MergeImagesWithOptions() error = %v", tt.layout, tt.columns, tt.maxPixels, err)
+		}
+		if !tt.ok && !errors.Is(err, card_image.ErrCanvasLimit) {
+			t.Errorf("%s/%d under %d pixels: MergeImagesWithOptions() error = %v, want ErrCanvasLimit", tt.layout, tt.columns, tt.maxPixels, err)
+		}
+	}
+}
+
+func TestMergeImagesZip(t *testing.T) {
+	geometry := LayoutOptions{DPI: DefaultDPI, CardWidth: 100, CardHeight: 50, Gutter: 10, Padding: 5}
+	cards := paletteCards()
+	broken := cards.Data[1]
+	broken.Id = "broken/card"
+	broken.Attributes.Source = "not base64!"
+	cards.Data = append(cards.Data[:1], broken, cards.Data[2])
+
+	resp, err := MergeImagesWithOptions(context.Background(), cards, Options{Layout: LayoutZip, Format: FormatPNG, Geometry: &geometry})
+	if err != nil {
+		t.Fatalf("MergeImagesWithOptions() error = %v", err)
+	}
+	if resp.ContentType != ZipContentType {
+		t.Errorf("ContentType = %q, want %q", resp.ContentType, ZipContentType)
+	}
+	if len(resp.FailedCards) != 1 || resp.FailedCards[0].CardId != broken.Id {
+		t.Errorf("FailedCards = %v", resp.FailedCards)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(resp.ImageContent), int64(len(resp.ImageContent)))
+	if err != nil {
+		t.Fatalf("failed to open ZIP archive: %v", err)
+	}
+	wantNames := []string{"01_a-card.png", "02_c-card.png"}
+	wantColours := []color.RGBA{palette[0], palette[2]}
+	if len(archive.File) != len(wantNames) {
+		t.Fatalf("archive has %d entries, want %d", len(archive.File), len(wantNames))
+	}
+	for i, f := range archive.File {
+		if f.Name != wantNames[i] {
+			t.Errorf("entry %d is named %q, want %q", i, f.Name, wantNames[i])
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		img, err := png.Decode(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("failed to decode %s: %v", f.Name, err)
+		}
+		if want := image.Rect(0, 0, 110, 60); img.Bounds() != want {
+			t.Errorf("%s bounds = %v, want %v", f.Name, img.Bounds(), want)
+		}
+		if got := color.RGBAModel.Convert(img.At(55, 30)).(color.RGBA); !closeTo(got, wantColours[i]) {
+			t.Errorf("%s has colour %v, want %v", f.Name, got, wantColours[i])
+		}
+	}
+}
+
+func TestEntryName(t *testing.T) {
+	for id, want := range map[string]string{
+		"medical-front": "medical-front",
+		"../etc/passwd": ".._etc_passwd",
+		"dental card":   "dental_card",
+		"..":            "card",
+		"":              "card",
+	} {
+		if got := entryName(id); got != want {
+			t.Errorf("entryName(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
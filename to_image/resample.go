@@ -0,0 +1,54 @@
+package to_image
+
+import (
+	"fmt"
+
+	"golang.org/x/image/draw"
+)
+
+// Interpolator is the resampling kernel card images are scaled with
+type Interpolator string
+
+const (
+	// InterpolatorNearest picks the closest source pixel; fastest, but small
+	// text such as Rx BIN/PCN numbers comes out jagged
+	InterpolatorNearest Interpolator = "nearest"
+	// InterpolatorApproxBiLinear blends neighbouring pixels cheaply
+	InterpolatorApproxBiLinear Interpolator = "approx-bilinear"
+	// InterpolatorBiLinear blends all source pixels under each output pixel
+	InterpolatorBiLinear Interpolator = "bilinear"
+	// InterpolatorCatmullRom uses a cubic kernel, keeping text sharp
+	InterpolatorCatmullRom Interpolator = "catmull-rom"
+)
+
+// DefaultInterpolator is used when none is requested. Cards are nearly always
+// downscaled, where CatmullRom keeps small text readable. It is also the
+// slowest kernel; the image merging benchmark reports the tradeoff.
+const DefaultInterpolator = InterpolatorCatmullRom
+
+var interpolators = map[Interpolator]draw.Interpolator{
+	InterpolatorNearest:        draw.NearestNeighbor,
+	InterpolatorApproxBiLinear: draw.ApproxBiLinear,
+	InterpolatorBiLinear:       draw.BiLinear,
+	InterpolatorCatmullRom:     draw.CatmullRom,
+}
+
+// ParseInterpolator converts a kernel name into an Interpolator, defaulting to
+// DefaultInterpolator
+func ParseInterpolator(name string) (Interpolator, error) {
+	if name == "" {
+		return DefaultInterpolator, nil
+	}
+	if _, ok := interpolators[Interpolator(name)]; !ok {
+		return "", fmt.Errorf("unknown interpolator %q", name)
+	}
+	return Interpolator(name), nil
+}
+
+// kernel returns the x/image/draw implementation of i
+func (i Interpolator) kernel() draw.Interpolator {
+	if k, ok := interpolators[i]; ok {
+		return k
+	}
+	return interpolators[DefaultInterpolator]
+}
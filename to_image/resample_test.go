@@ -0,0 +1,88 @@
+package to_image
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"main/data"
+	"testing"
+)
+
+func TestParseInterpolator(t *testing.T) {
+	for name, want := range map[string]Interpolator{
+		"":                DefaultInterpolator,
+		"nearest":         InterpolatorNearest,
+		"approx-bilinear": InterpolatorApproxBiLinear,
+		"bilinear":        InterpolatorBiLinear,
+		"catmull-rom":     InterpolatorCatmullRom,
+	} {
+		if got, err := ParseInterpolator(name); err != nil || got != want {
+			t.Errorf("ParseInterpolator(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseInterpolator("lanczos"); err == nil {
+		t.Error("ParseInterpolator() accepted an unknown kernel")
+	}
+}
+
+// stripedCard returns a card of one pixel wide black and white stripes, twice
+// the size of a card slot, like fine print scanned at a high resolution
+func stripedCard() data.IdCard {
+	img := image.NewGray(image.Rect(0, 0, 2*cardWidth, 2*cardHeight))
+	for y := 0; y < 2*cardHeight; y++ {
+		for x := 0; x < 2*cardWidth; x++ {
+			if x%2 == 0 {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		panic(err)
+	}
+	return data.IdCard{
+		Id: "striped-card",
+		Attributes: data.IdCardAttributes{
+			Type:   data.IdCardAttributesTypeBase64,
+			Face:   data.IdCardAttributesFaceFront,
+			Source: base64.StdEncoding.EncodeToString(buf.Bytes()),
+		},
+	}
+}
+
+func TestMergeImagesInterpolator(t *testing.T) {
+	cards := data.IdCardsResponseSchema{Data: []data.IdCard{stripedCard()}}
+
+	tests := []struct {
+		interpolator Interpolator
+		smooth       bool
+	}{
+		{InterpolatorNearest, false},
+		{InterpolatorBiLinear, true},
+		{InterpolatorCatmullRom, true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		resp, err := MergeImagesWithOptions(context.Background(), cards, Options{Format: FormatPNG, Interpolator: tt.interpolator})
+		if err != nil {
+			t.Fatalf("MergeImagesWithOptions(%q) error = %v", tt.interpolator, err)
+		}
+		merged, err := png.Decode(bytes.NewReader(resp.ImageContent))
+		if err != nil {
+			t.Fatalf("failed to decode merged image: %v", err)
+		}
+
+		// Halving the stripes averages them to grey; picking pixels keeps
+		// them black or white
+		y := sideMargin + cardHeight/2
+		for x := sideMargin + cardWidth/2; x < sideMargin+cardWidth/2+4; x++ {
+			gray := color.GrayModel.Convert(merged.At(x, y)).(color.Gray).Y
+			if smooth := gray > 64 && gray < 192; smooth != tt.smooth {
+				t.Errorf("%q: pixel at x=%d is %d, want smooth = %v", tt.interpolator, x, gray, tt.smooth)
+			}
+		}
+	}
+}
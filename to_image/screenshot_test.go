@@ -0,0 +1,50 @@
+//go:build chrome
+
+package to_image
+
+import (
+	"context"
+	"main/data"
+	"testing"
+)
+
+// TestScreenshotRasterizer checks what Chrome captures: run with
+// go test -tags chrome ./to_image on a machine with Chrome
+func TestScreenshotRasterizer(t *testing.T) {
+	html := data.MockHTMLIdCardFront.Attributes.Source
+	var r ScreenshotRasterizer
+
+	low, err := r.Rasterize(context.Background(), html, 150)
+	if err != nil {
+		t.Fatalf("Rasterize() at 150 DPI error = %v", err)
+	}
+	high, err := r.Rasterize(context.Background(), html, 300)
+	if err != nil {
+		t.Fatalf("Rasterize() at 300 DPI error = %v", err)
+	}
+
+	// Doubling the DPI doubles both sides, give or take rounding
+	lw, lh, hw, hh := low.Bounds().Dx(), low.Bounds().Dy(), high.Bounds().Dx(), high.Bounds().Dy()
+	if lw == 0 || lh == 0 {
+		t.Fatalf("150 DPI image is empty: %v", low.Bounds())
+	}
+	if abs(hw-2*lw) > 2 || abs(hh-2*lh) > 2 {
+		t.Errorf("300 DPI image is %d × %d, want twice the 150 DPI %d × %d", hw, hh, lw, lh)
+	}
+
+	// Only the card is captured: a landscape card, not a portrait Letter
+	// page of 8.5 × 11 inches with the card at the top
+	if hw >= 8.5*300 || hh >= 11*300/2 {
+		t.Errorf("300 DPI image is %d × %d, want the card only", hw, hh)
+	}
+	if hw <= hh {
+		t.Errorf("300 DPI image is %d × %d, want a landscape card", hw, hh)
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,102 @@
+package to_image
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// DefaultTrimThreshold is the background threshold used when TrimOptions.Threshold is 0
+const DefaultTrimThreshold = 16
+
+// TrimOptions controls how the whitespace around rasterised HTML cards is cut
+// away so they fill their slot like image cards do
+type TrimOptions struct {
+	// Disabled keeps rasterised HTML cards as rendered
+	Disabled bool
+	// Threshold is the largest per channel difference (1-255) from the
+	// background colour that still counts as background. The background is
+	// the colour of the top left pixel.
+	Threshold int
+	// Padding is the number of background pixels kept around the content
+	Padding int
+}
+
+// trimImage crops img to the bounding box of the pixels that differ from its
+// background, plus padding. Images that are all background are returned as is.
+func trimImage(img image.Image, opts TrimOptions) image.Image {
+	if opts.Disabled {
+		return img
+	}
+	threshold := opts.Threshold
+	if threshold <= 0 {
+		threshold = DefaultTrimThreshold
+	}
+
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return img
+	}
+	bg := color.RGBAModel.Convert(img.At(bounds.Min.X, bounds.Min.Y)).(color.RGBA)
+
+	isBackground := func(x, y int) bool {
+		return nearColor(color.RGBAModel.Convert(img.At(x, y)).(color.RGBA), bg, threshold)
+	}
+	if rgba, ok := img.(*image.RGBA); ok {
+		// Avoid the interface conversions of At for the common case
+		isBackground = func(x, y int) bool {
+			i := rgba.PixOffset(x, y)
+			p := rgba.Pix[i : i+4 : i+4]
+			return nearColor(color.RGBA{R: p[0], G: p[1], B: p[2], A: p[3]}, bg, threshold)
+		}
+	}
+
+	content := image.Rectangle{Min: bounds.Max, Max: bounds.Min}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if isBackground(x, y) {
+				continue
+			}
+			if x < content.Min.X {
+				content.Min.X = x
+			}
+			if y < content.Min.Y {
+				content.Min.Y = y
+			}
+			if x >= content.Max.X {
+				content.Max.X = x + 1
+			}
+			if y >= content.Max.Y {
+				content.Max.Y = y + 1
+			}
+		}
+	}
+	if content.Empty() {
+		return img
+	}
+
+	content = content.Inset(-opts.Padding).Intersect(bounds)
+	if content == bounds {
+		return img
+	}
+	if sub, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(content)
+	}
+	trimmed := image.NewRGBA(image.Rect(0, 0, content.Dx(), content.Dy()))
+	draw.Draw(trimmed, trimmed.Bounds(), img, content.Min, draw.Src)
+	return trimmed
+}
+
+// nearColor reports whether every channel of a is within threshold of b
+func nearColor(a, b color.RGBA, threshold int) bool {
+	diff := func(x, y uint8) int {
+		if x > y {
+			return int(x - y)
+		}
+		return int(y - x)
+	}
+	return diff(a.R, b.R) <= threshold && diff(a.G, b.G) <= threshold &&
+		diff(a.B, b.B) <= threshold && diff(a.A, b.A) <= threshold
+}
@@ -0,0 +1,104 @@
+package to_image
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"main/data"
+	"testing"
+
+	"github.com/sunshineplan/imgconv"
+)
+
+// pageWithCard returns a white Letter page at 100 DPI with a card drawn at card
+func pageWithCard(card image.Rectangle, c color.Color) *image.RGBA {
+	page := image.NewRGBA(image.Rect(0, 0, 850, 1100))
+	draw.Draw(page, page.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(page, card, image.NewUniform(c), image.Point{}, draw.Src)
+	return page
+}
+
+func TestTrimImage(t *testing.T) {
+	card := image.Rect(40, 40, 380, 254)
+	page := pageWithCard(card, color.RGBA{R: 200, A: 255})
+	// Scanner noise close to white
+	page.Set(700, 900, color.RGBA{R: 245, G: 245, B: 245, A: 255})
+
+	tests := []struct {
+		name string
+		opts TrimOptions
+		want image.Rectangle
+	}{
+		{name: "default", opts: TrimOptions{}, want: card},
+		{name: "padding", opts: TrimOptions{Padding: 10}, want: card.Inset(-10)},
+		{name: "padding clipped to page", opts: TrimOptions{Padding: 100}, want: image.Rect(0, 0, 480, 354)},
+		{name: "strict threshold keeps noise", opts: TrimOptions{Threshold: 1}, want: image.Rect(40, 40, 701, 901)},
+		{name: "disabled", opts: TrimOptions{Disabled: true}, want: page.Bounds()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimImage(page, tt.opts).Bounds(); got != tt.want {
+				t.Errorf("trimImage() bounds = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrimImageBlankPage(t *testing.T) {
+	page := pageWithCard(image.Rectangle{}, color.White)
+	if got := trimImage(page, TrimOptions{}); got.Bounds() != page.Bounds() {
+		t.Errorf("trimImage() bounds = %v, want the whole page", got.Bounds())
+	}
+}
+
+func TestTrimImageGeneric(t *testing.T) {
+	src := pageWithCard(image.Rect(100, 200, 300, 326), color.Black)
+	gray := image.NewGray(src.Bounds())
+	draw.Draw(gray, gray.Bounds(), src, image.Point{}, draw.Src)
+
+	if got := trimImage(gray, TrimOptions{}).Bounds(); got != image.Rect(100, 200, 300, 326) {
+		t.Errorf("trimImage() bounds = %v", got)
+	}
+}
+
+// pageRasterizer renders every HTML card as a small card on a blank page, like
+// the PDF round trip does
+type pageRasterizer struct {
+	color color.RGBA
+}
+
+func (r pageRasterizer) Name() string {
+	return "page"
+}
+
+func (r pageRasterizer) Rasterize(ctx context.Context, html string, dpi float64) (image.Image, error) {
+	return pageWithCard(image.Rect(40, 40, 380, 254), r.color), nil
+}
+
+func TestMergeImagesTrimsHTMLCards(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	resp, err := MergeImagesWithOptions(context.Background(), data.IdCardsResponseSchema{
+		Data: []data.IdCard{data.MockHTMLIdCardFront},
+	}, Options{HTMLRasterizer: pageRasterizer{color: red}, Format: FormatPNG})
+	if err != nil {
+		t.Fatalf("MergeImagesWithOptions() error = %v", err)
+	}
+
+	merged, err := imgconv.Decode(bytes.NewReader(resp.ImageContent))
+	if err != nil {
+		t.Fatalf("failed to decode merged image: %v", err)
+	}
+
+	// A trimmed card fills the slot width, like an image card does
+	y := sideMargin + cardHeight/2
+	for _, x := range []int{sideMargin + 5, sideMargin + cardWidth/2, sideMargin + cardWidth - 5} {
+		r, g, b, _ := merged.At(x, y).RGBA()
+		got := color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 255}
+		if !closeTo(got, red) {
+			t.Errorf("colour at x=%d = %v, want %v", x, got, red)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package to_pdf
+
+import (
+	"context"
+	"fmt"
+	"main/browser_pool"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+const mmPerInch = 25.4
+
+// WaitForImagesJS resolves once every <img> in the document has loaded or failed
+const WaitForImagesJS = `Promise.all(Array.from(document.images)
+	.filter(img => !img.complete)
+	.map(img => new Promise(resolve => { img.onload = img.onerror = resolve; })))`
+
+// ChromedpRenderer renders PDFs with headless Chrome through chromedp. The
+// render is bounded by the context, see Options.Timeout.
+type ChromedpRenderer struct {
+	// Pool renders in a shared browser; a fresh Chrome is started per render when nil
+	Pool *browser_pool.Pool
+}
+
+// NewChromedpRenderer creates a renderer backed by headless Chrome
+func NewChromedpRenderer() *ChromedpRenderer {
+	return &ChromedpRenderer{}
+}
+
+func (r *ChromedpRenderer) Name() string {
+	return RendererChromedp
+}
+
+// Tagged is true: Chrome writes a structure tree with the card AltText
+func (r *ChromedpRenderer) Tagged() bool {
+	return true
+}
+
+func (r *ChromedpRenderer) Render(ctx context.Context, html []byte, pageSetup Page) ([]byte, error) {
+	run := chromedp.Run
+	if r.Pool != nil {
+		run = r.Pool.Run
+	} else {
+		// Create a new Chrome instance
+		var cancel context.CancelFunc
+		ctx, cancel = chromedp.NewContext(ctx)
+		defer cancel()
+	}
+
+	var pdfContent []byte
+	err := run(ctx,
+		chromedp.Navigate("about:blank"),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			frameTree, err := page.GetFrameTree().Do(ctx)
+			if err != nil {
+				return err
+			}
+			return page.SetDocumentContent(frameTree.Frame.ID, string(html)).Do(ctx)
+		}),
+		chromedp.Evaluate(WaitForImagesJS, nil, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		}),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			margin := pageSetup.Margin / mmPerInch
+			pdfContent, _, err = page.PrintToPDF().
+				WithPrintBackground(true).
+				// Tag the card figures with their alternative text for screen readers
+				WithGenerateTaggedPDF(true).
+				WithGenerateDocumentOutline(true).
+				WithPaperWidth(pageSetup.Width / mmPerInch).
+				WithPaperHeight(pageSetup.Height / mmPerInch).
+				WithMarginTop(margin).
+				WithMarginBottom(margin).
+				WithMarginLeft(margin).
+				WithMarginRight(margin).
+				Do(ctx)
+			return err
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate PDF: %w", err)
+	}
+	return pdfContent, nil
+}
@@ -0,0 +1,160 @@
+package to_pdf
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"main/data"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// Permission is a set of actions a reader of an encrypted PDF is allowed
+type Permission uint
+
+const (
+	// PermissionPrint allows printing at full quality
+	PermissionPrint Permission = 1 << iota
+	// PermissionCopy allows copying text and images out of the document
+	PermissionCopy
+	// PermissionModify allows editing, assembling and filling in the document
+	PermissionModify
+	// PermissionAnnotate allows adding comments and annotations
+	PermissionAnnotate
+)
+
+// DefaultPermissions lets members print their cards but not edit or copy them
+const DefaultPermissions = PermissionPrint
+
+// permissionNames are the names accepted by ParsePermissions
+var permissionNames = map[string]Permission{
+	"print":    PermissionPrint,
+	"copy":     PermissionCopy,
+	"modify":   PermissionModify,
+	"annotate": PermissionAnnotate,
+}
+
+// ParsePermissions converts a comma-separated list of permission names
+// (print, copy, modify, annotate) into a Permission. "none" allows nothing.
+func ParsePermissions(list string) (Permission, error) {
+	var perms Permission
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "none" {
+			continue
+		}
+		p, ok := permissionNames[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown permission %q", name)
+		}
+		perms |= p
+	}
+	return perms, nil
+}
+
+// Encryption password protects a PDF with AES-256
+type Encryption struct {
+	// UserPassword is needed to open the document. When empty anyone can open
+	// it, but Permissions still apply.
+	UserPassword string
+	// OwnerPassword lifts the restrictions of Permissions. A random password is
+	// used when empty so that nobody can lift them.
+	OwnerPassword string
+	// Permissions are the actions allowed to whoever opens the document with
+	// the user password. Extracting text for accessibility is always allowed
+	// so screen readers keep working.
+	Permissions Permission
+}
+
+// Bits of the PDF permission flags (P entry of the encryption dictionary)
+const (
+	// pdfPermissionsNone has only the reserved bits set, as model.PermissionsNone
+	pdfPermissionsNone     = 0xF0C3
+	pdfPermitPrint         = 1 << 2
+	pdfPermitModify        = 1 << 3
+	pdfPermitCopy          = 1 << 4
+	pdfPermitAnnotate      = 1 << 5
+	pdfPermitFillForms     = 1 << 8
+	pdfPermitAccessibility = 1 << 9
+	pdfPermitAssemble      = 1 << 10
+	pdfPermitHighQualPrint = 1 << 11
+)
+
+// flags converts the permissions into PDF permission flags
+func (p Permission) flags() int16 {
+	flags := uint16(pdfPermissionsNone | pdfPermitAccessibility)
+	if p&PermissionPrint != 0 {
+		flags |= pdfPermitPrint | pdfPermitHighQualPrint
+	}
+	if p&PermissionCopy != 0 {
+		flags |= pdfPermitCopy
+	}
+	if p&PermissionModify != 0 {
+		flags |= pdfPermitModify | pdfPermitAssemble | pdfPermitFillForms
+	}
+	if p&PermissionAnnotate != 0 {
+		flags |= pdfPermitAnnotate | pdfPermitFillForms
+	}
+	return int16(uint16(flags))
+}
+
+// ownerPasswordBytes is the length of generated owner passwords before hex encoding
+const ownerPasswordBytes = 32
+
+// disableConfigDir keeps pdfcpu from writing a configuration directory
+var disableConfigDir sync.Once
+
+// encryptPDF encrypts a rendered PDF with AES-256
+func encryptPDF(pdf []byte, enc Encryption) ([]byte, error) {
+	ownerPW := enc.OwnerPassword
+	if ownerPW == "" {
+		b := make([]byte, ownerPasswordBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate owner password: %w", err)
+		}
+		ownerPW = hex.EncodeToString(b)
+	}
+
+	disableConfigDir.Do(api.DisableConfigDir)
+	conf := model.NewAESConfiguration(enc.UserPassword, ownerPW, 256)
+	conf.Permissions = enc.Permissions.flags()
+
+	var out bytes.Buffer
+	if err := api.Encrypt(bytes.NewReader(pdf), &out, conf); err != nil {
+		return nil, fmt.Errorf("failed to encrypt PDF: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// ErrNoMemberID is returned by MemberIDPassword when no card carries a member ID
+var ErrNoMemberID = errors.New("no member ID on the ID cards")
+
+// memberIDPattern finds the member ID printed on a card
+var memberIDPattern = regexp.MustCompile(`(?i)member\s+id:?\s*([a-z0-9-]+)`)
+
+// memberID returns the member ID printed on the first card that has one, as
+// read from its AltText
+func memberID(idCardsResp data.IdCardsResponseSchema) (string, bool) {
+	for _, card := range idCardsResp.Data {
+		if m := memberIDPattern.FindStringSubmatch(card.Attributes.AltText); m != nil {
+			return m[1], true
+		}
+	}
+	return "", false
+}
+
+// MemberIDPassword derives a user password from the member ID printed on the
+// cards, so members can open the PDF with a value they already know
+func MemberIDPassword(idCardsResp data.IdCardsResponseSchema) (string, error) {
+	id, ok := memberID(idCardsResp)
+	if !ok {
+		return "", ErrNoMemberID
+	}
+	return id, nil
+}
@@ -0,0 +1,133 @@
+package to_pdf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"main/data"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// minimalPDF builds a valid one page PDF
+func minimalPDF() []byte {
+	return testPDF("<< >>")
+}
+
+// testPDF builds a one page PDF with the given page resources. The extra
+// objects are numbered from 5. The file is padded to the size pdfcpu needs to
+// find its trailer.
+func testPDF(resources string, extra ...string) []byte {
+	objects := append([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources " + resources + " >>",
+		"<< /Length 0 >>\nstream\n\nendstream",
+	}, extra...)
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	buf.WriteString("%" + strings.Repeat("-", 512) + "\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// pdfRenderer returns a fixed PDF
+type pdfRenderer struct {
+	pdf []byte
+}
+
+func (r pdfRenderer) Name() string {
+	return "pdf"
+}
+
+func (r pdfRenderer) Render(context.Context, []byte, Page) ([]byte, error) {
+	return r.pdf, nil
+}
+
+func TestGeneratePDFEncryption(t *testing.T) {
+	idCardsResp := data.IdCardsResponseSchema{Data: []data.IdCard{data.MockImageIdCardFront}}
+	opts := Options{
+		Renderer:   pdfRenderer{pdf: minimalPDF()},
+		Encryption: &Encryption{UserPassword: "s3cret", Permissions: PermissionPrint},
+	}
+
+	resp, err := GeneratePDF(context.Background(), idCardsResp, opts)
+	if err != nil {
+		t.Fatalf("GeneratePDF() error = %v", err)
+	}
+	if !bytes.Contains(resp.PDFContent, []byte("/Encrypt")) {
+		t.Fatal("PDF is not encrypted")
+	}
+
+	if _, err := api.ReadContext(bytes.NewReader(resp.PDFContent), model.NewAESConfiguration("wrong", "", 256)); err == nil {
+		t.Error("PDF opens with a wrong password")
+	}
+
+	perms, err := api.GetPermissions(bytes.NewReader(resp.PDFContent), model.NewAESConfiguration("s3cret", "", 256))
+	if err != nil {
+		t.Fatalf("PDF does not open with the user password: %v", err)
+	}
+	if perms == nil || *perms != PermissionPrint.flags() {
+		t.Errorf("permissions = %v, want %d", perms, PermissionPrint.flags())
+	}
+}
+
+func TestPermissionFlags(t *testing.T) {
+	tests := []struct {
+		perms     Permission
+		allowed   uint16
+		forbidden uint16
+	}{
+		{0, pdfPermitAccessibility, pdfPermitPrint | pdfPermitCopy | pdfPermitModify | pdfPermitAnnotate},
+		{PermissionPrint, pdfPermitPrint | pdfPermitHighQualPrint, pdfPermitCopy | pdfPermitModify},
+		{PermissionCopy | PermissionModify, pdfPermitCopy | pdfPermitModify | pdfPermitAssemble, pdfPermitPrint},
+	}
+	for _, tt := range tests {
+		flags := uint16(tt.perms.flags())
+		if flags&tt.allowed != tt.allowed || flags&tt.forbidden != 0 {
+			t.Errorf("Permission(%d).flags() = %#x", tt.perms, flags)
+		}
+	}
+}
+
+func TestParsePermissions(t *testing.T) {
+	if got, err := ParsePermissions("print, Copy"); err != nil || got != PermissionPrint|PermissionCopy {
+		t.Errorf("ParsePermissions() = %v, %v", got, err)
+	}
+	if got, err := ParsePermissions("none"); err != nil || got != 0 {
+		t.Errorf("ParsePermissions(none) = %v, %v", got, err)
+	}
+	if _, err := ParsePermissions("print,fax"); err == nil {
+		t.Error("ParsePermissions() accepted an unknown permission")
+	}
+}
+
+func TestMemberIDPassword(t *testing.T) {
+	password, err := MemberIDPassword(data.IdCardsResponseSchema{
+		Data: []data.IdCard{data.MockHTMLIdCardBack, data.MockImageIdCardFront},
+	})
+	if err != nil {
+		t.Fatalf("MemberIDPassword() error = %v", err)
+	}
+	if !strings.Contains(data.MockImageIdCardFront.Attributes.AltText, "Member ID: "+password) {
+		t.Errorf("MemberIDPassword() = %q", password)
+	}
+
+	if _, err := MemberIDPassword(data.IdCardsResponseSchema{}); err != ErrNoMemberID {
+		t.Errorf("MemberIDPassword() error = %v, want ErrNoMemberID", err)
+	}
+}
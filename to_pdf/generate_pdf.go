@@ -1,97 +1,291 @@
 package to_pdf
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"html/template"
+	"main/card_error"
+	"main/card_image"
 	"main/data"
+	"main/fetcher"
+	"main/sanitize"
 	"strings"
 	"time"
-
-	"github.com/SebastiaanKlippert/go-wkhtmltopdf"
 )
 
 // GeneratePDFResponse contains the result of PDF generation
 type GeneratePDFResponse struct {
 	PDFContent []byte
 	FileName   string
+	// FailedCards lists the cards left out of a lenient PDF, in card order
+	FailedCards []*card_error.CardError
+}
+
+// Layout controls how card faces are arranged on the page
+type Layout string
+
+const (
+	// LayoutStacked puts every face below the previous one
+	LayoutStacked Layout = "stacked"
+	// LayoutPaired puts the front and back faces of a card side by side
+	LayoutPaired Layout = "paired"
+	// LayoutPrint prints every face at real CR80 size with cut marks
+	LayoutPrint Layout = "print"
+)
+
+// ParseLayout converts a layout name into a Layout, defaulting to LayoutStacked
+func ParseLayout(name string) (Layout, error) {
+	switch Layout(name) {
+	case "", LayoutStacked:
+		return LayoutStacked, nil
+	case LayoutPaired, LayoutPrint:
+		return Layout(name), nil
+	}
+	return "", fmt.Errorf("unknown layout %q", name)
+}
+
+// Options controls how a PDF is generated
+type Options struct {
+	// Renderer converts the HTML page to PDF; DefaultRenderer() is used when nil
+	Renderer Renderer
+	// Layout arranges the card faces; LayoutStacked is used when empty
+	Layout Layout
+	// Timeout bounds the render stage; DefaultTimeout is used when 0
+	Timeout time.Duration
+	// Fetcher downloads remote card images; fetcher.Default() is used when nil
+	Fetcher *fetcher.Fetcher
+	// Limits caps the size of card images handed to the renderer; zero
+	// fields fall back to card_image.DefaultLimits
+	Limits card_image.Limits
+	// Strict fails the whole PDF with a *card_error.CardsError when the image
+	// of any card cannot be fetched or decoded. Otherwise those cards are left
+	// out and listed in FailedCards.
+	Strict bool
+	// Theme styles the page; DefaultTheme() is used when nil
+	Theme *Theme
+	// Title is the document title read out by screen readers; DefaultTitle is used when empty
+	Title string
+	// Lang is the BCP 47 language of the document; DefaultLang is used when empty
+	Lang string
+	// TextLayer lays the AltText of every image card invisibly over its image,
+	// so the card details can be searched and copied from the PDF
+	TextLayer bool
+	// Encryption password protects the PDF and restricts what readers may do
+	// with it; the PDF is not encrypted when nil
+	Encryption *Encryption
+	// Archival produces a PDF/A-2b file for long term storage, recording the
+	// member, issue date and card ids in its XMP metadata. Generation fails if
+	// CheckPDFAMarkers or the Validator reject the result. Archival PDFs
+	// cannot be encrypted.
+	Archival bool
+	// Validator runs veraPDF over the finished archival PDF, signature
+	// included; only CheckPDFAMarkers is run when nil
+	Validator *VeraPDF
+	// Signer signs the PDF on behalf of the card issuer; the PDF is not signed
+	// when nil. Signed PDFs cannot be encrypted.
+	Signer *Signer
 }
 
+// ErrArchivalEncryption is returned when an archival PDF is asked to be
+// encrypted, which PDF/A forbids
+var ErrArchivalEncryption = errors.New("archival PDFs cannot be encrypted")
+
+// DefaultTimeout bounds how long a renderer may take to produce a PDF
+const DefaultTimeout = 60 * time.Second
+
+// Document metadata used when Options leaves it empty
+const (
+	DefaultTitle = "ID Cards"
+	DefaultLang  = "en"
+)
+
 func GeneratePDFFromIDCards(ctx context.Context, idCardsResp data.IdCardsResponseSchema) (*GeneratePDFResponse, error) {
-	pdfg, err := wkhtmltopdf.NewPDFGenerator()
+	return GeneratePDF(ctx, idCardsResp, Options{})
+}
+
+// GeneratePDF renders the ID cards to a PDF using the given options
+func GeneratePDF(ctx context.Context, idCardsResp data.IdCardsResponseSchema, opts Options) (*GeneratePDFResponse, error) {
+	if opts.Archival && opts.Encryption != nil {
+		return nil, ErrArchivalEncryption
+	}
+	if opts.Signer != nil && opts.Encryption != nil {
+		return nil, ErrSignedEncryption
+	}
+
+	renderer := opts.Renderer
+	if renderer == nil {
+		renderer = DefaultRenderer()
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	imageFetcher := opts.Fetcher
+	if imageFetcher == nil {
+		imageFetcher = fetcher.Default()
+	}
+	total := len(idCardsResp.Data)
+	idCardsResp, failed, err := inlineImages(ctx, imageFetcher, card_image.NewBudget(opts.Limits), idCardsResp)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize PDF generator: %w", err)
-	}
-
-	pdfg.Dpi.Set(300)
-	pdfg.PageSize.Set(wkhtmltopdf.PageSizeLetter)
-	pdfg.MarginTop.Set(40)
-	pdfg.MarginBottom.Set(40)
-	pdfg.MarginLeft.Set(40)
-	pdfg.MarginRight.Set(40)
-
-	var sb strings.Builder
-	for _, card := range idCardsResp.Data {
-		if card.Attributes.Type == data.IdCardAttributesTypeHTML {
-			sb.WriteString(`<div class="card">`)
-			sb.WriteString(card.Attributes.Source)
-			sb.WriteString(`</div>`)
-		} else {
-			var imgSrc string
-			if isURL(card.Attributes.Source) {
-				imgSrc = card.Attributes.Source
-			} else {
-				imgSrc = fmt.Sprintf("data:image/png;base64,%s", card.Attributes.Source)
-			}
-			sb.WriteString(fmt.Sprintf(
-				`<div class="card"><img src="%s" alt="%s Card"></div>`,
-				imgSrc, card.Attributes.Face))
-		}
+		return nil, err
+	}
+	if len(failed) > 0 && (opts.Strict || len(idCardsResp.Data) == 0) {
+		return nil, &card_error.CardsError{Cards: failed, Total: total}
 	}
 
-	html := fmt.Sprintf(`
-	  <!DOCTYPE html>
-	  <html>
-	  <head>
-	   <style>
-		body {
-		 margin: 0;
-		 padding: 0;
-		 font-family: Arial, sans-serif;
-		}
-		.card {
-		 width: 100%%;
-		 margin-bottom: 20px;
+	theme := opts.Theme
+	if theme == nil {
+		theme = DefaultTheme()
+	}
+	doc := documentData{Title: opts.Title, Lang: opts.Lang, TextLayer: opts.TextLayer}
+	if doc.Title == "" {
+		doc.Title = DefaultTitle
+	}
+	if doc.Lang == "" {
+		doc.Lang = DefaultLang
+	}
+
+	var html string
+	page := LetterPage
+	if opts.Layout == LayoutPrint {
+		html, err = buildPrintHTML(theme, doc, idCardsResp)
+		page = printPage
+	} else {
+		html, err = buildHTML(theme, doc, idCardsResp, opts.Layout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pdfContent, err := renderer.Render(ctx, []byte(html), page)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			// Engines report a killed process, keep the cancellation cause visible
+			return nil, fmt.Errorf("%w: %v", ctxErr, err)
 		}
-		img {
-		 width: 100%%;
-		 height: auto;
+		return nil, err
+	}
+
+	now := time.Now()
+	if opts.Archival {
+		if pdfContent, err = archive(pdfContent, doc, idCardsResp, now); err != nil {
+			return nil, err
 		}
-		.card-info {
-		 margin-top: 5px;
-		 font-size: 12px;
+	}
+
+	if opts.Signer != nil {
+		if pdfContent, err = opts.Signer.sign(ctx, pdfContent, now); err != nil {
+			return nil, fmt.Errorf("failed to sign PDF: %w", err)
 		}
-	   </style>
-	  </head>
-	  <body>
-	   %s
-	  </body>
-	  </html>`, sb.String())
+	}
 
-	page := wkhtmltopdf.NewPageReader(bytes.NewReader([]byte(html)))
-	pdfg.AddPage(page)
+	if opts.Archival && opts.Validator != nil {
+		if err := opts.Validator.Validate(ctx, pdfContent); err != nil {
+			return nil, err
+		}
+	}
 
-	if err = pdfg.Create(); err != nil {
-		return nil, fmt.Errorf("failed to generate PDF: %w", err)
+	if opts.Encryption != nil {
+		if pdfContent, err = encryptPDF(pdfContent, *opts.Encryption); err != nil {
+			return nil, err
+		}
 	}
 
-	fileName := fmt.Sprintf("id_cards_%s.pdf", time.Now().Format("20060102_150405"))
+	fileName := fmt.Sprintf("id_cards_%s.pdf", now.Format("20060102_150405"))
 	return &GeneratePDFResponse{
-		PDFContent: pdfg.Bytes(),
-		FileName:   fileName,
+		PDFContent:  pdfContent,
+		FileName:    fileName,
+		FailedCards: failed,
 	}, nil
 }
 
+// documentData describes the PDF document as a whole
+type documentData struct {
+	Title string
+	Lang  string
+	// TextLayer puts the AltText of image cards under the image as text
+	TextLayer bool
+}
+
+// pageData is the data of the "page" template
+type pageData struct {
+	documentData
+	Paired bool
+	Cards  []*cardView // every face, in order, for the stacked layout
+	Rows   []cardRow   // one row per card for the paired layout
+}
+
+// cardRow is a row of the paired layout: either a combined face or the front
+// and back of a card, each of which may be missing
+type cardRow struct {
+	Combined, Front, Back *cardView
+}
+
+// cardView is the data of the "card" partials
+type cardView struct {
+	Face    string
+	AltText string
+	// Alt is the alternative text of the card figure: AltText, or the face
+	// when the card has none
+	Alt    string
+	IsHTML bool
+	// HTML is the sanitised markup of an HTML card
+	HTML template.HTML
+	// Src is the image source of an image card
+	Src template.URL
+	// TextLayer is the AltText laid invisibly over an image card so it can be
+	// searched and copied; empty when Options.TextLayer is off
+	TextLayer string
+}
+
+// newCardView prepares a card for the templates. HTML sources come from
+// carrier extensions and are sanitised; everything else is escaped by the
+// templates.
+func newCardView(card data.IdCard, textLayer bool) *cardView {
+	view := &cardView{Face: string(card.Attributes.Face), AltText: card.Attributes.AltText, Alt: card.Attributes.AltText}
+	if view.Alt == "" {
+		view.Alt = view.Face + " Card"
+	}
+	if card.Attributes.Type == data.IdCardAttributesTypeHTML {
+		view.IsHTML = true
+		view.HTML = template.HTML(sanitize.HTML(card.Attributes.Source))
+		return view
+	}
+
+	// Sources are typed data URIs once inlineImages has run
+	view.Src = template.URL(card.Attributes.Source)
+	if textLayer {
+		view.TextLayer = strings.TrimSpace(card.Attributes.AltText)
+	}
+	return view
+}
+
+// buildHTML builds the HTML page that is handed to the PDF renderers
+func buildHTML(theme *Theme, doc documentData, idCardsResp data.IdCardsResponseSchema, layout Layout) (string, error) {
+	page := pageData{documentData: doc, Paired: layout == LayoutPaired}
+	if page.Paired {
+		at := func(i int) *cardView {
+			if i == -1 {
+				return nil
+			}
+			return newCardView(idCardsResp.Data[i], doc.TextLayer)
+		}
+		for _, pair := range data.PairIdCards(idCardsResp.Data) {
+			page.Rows = append(page.Rows, cardRow{Combined: at(pair.Combined), Front: at(pair.Front), Back: at(pair.Back)})
+		}
+	} else {
+		for _, card := range idCardsResp.Data {
+			page.Cards = append(page.Cards, newCardView(card, doc.TextLayer))
+		}
+	}
+	return theme.execute(templatePage, page)
+}
+
 func isURL(s string) bool {
 	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
 }
@@ -0,0 +1,125 @@
+package to_pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// sRGBProfileDescription names the output intent of archival PDFs
+const sRGBProfileDescription = "sRGB IEC61966-2.1"
+
+// sRGB primaries and white point adapted to the D50 illuminant of the ICC
+// profile connection space
+var (
+	iccWhitePoint = [3]float64{0.9642, 1.0, 0.8249}
+	iccRed        = [3]float64{0.4360747, 0.2225045, 0.0139322}
+	iccGreen      = [3]float64{0.3850649, 0.7168786, 0.0971045}
+	iccBlue       = [3]float64{0.1430804, 0.0606169, 0.7141733}
+)
+
+// iccGamma approximates the sRGB tone curve
+const iccGamma = 2.2
+
+// sRGBProfile is an ICC v2 display profile with sRGB primaries, the colour
+// space Chrome and wkhtmltopdf paint in. It is embedded as the output intent
+// of archival PDFs so their DeviceRGB colours have a defined meaning.
+var sRGBProfile = buildRGBProfile(sRGBProfileDescription)
+
+// iccTag is an entry of an ICC profile tag table
+type iccTag struct {
+	sig  string
+	data []byte
+}
+
+// buildRGBProfile encodes a matrix/TRC RGB display profile
+func buildRGBProfile(description string) []byte {
+	trc := iccCurve(iccGamma)
+	tags := []iccTag{
+		{"desc", iccTextDescription(description)},
+		{"cprt", iccText("No copyright, use freely")},
+		{"wtpt", iccXYZ(iccWhitePoint)},
+		{"rXYZ", iccXYZ(iccRed)},
+		{"gXYZ", iccXYZ(iccGreen)},
+		{"bXYZ", iccXYZ(iccBlue)},
+		{"rTRC", trc},
+		{"gTRC", trc},
+		{"bTRC", trc},
+	}
+
+	const headerSize = 128
+	tableSize := 4 + 12*len(tags)
+	offset := headerSize + tableSize
+
+	var table, body bytes.Buffer
+	binary.Write(&table, binary.BigEndian, uint32(len(tags)))
+	for _, tag := range tags {
+		table.WriteString(tag.sig)
+		binary.Write(&table, binary.BigEndian, uint32(offset+body.Len()))
+		binary.Write(&table, binary.BigEndian, uint32(len(tag.data)))
+		body.Write(tag.data)
+		// Tag data starts on a 4 byte boundary
+		for body.Len()%4 != 0 {
+			body.WriteByte(0)
+		}
+	}
+
+	var header bytes.Buffer
+	binary.Write(&header, binary.BigEndian, uint32(offset+body.Len()))
+	header.Write(make([]byte, 4))                               // preferred CMM
+	binary.Write(&header, binary.BigEndian, uint32(0x02100000)) // version 2.1
+	header.WriteString("mntr")                                  // display device
+	header.WriteString("RGB ")
+	header.WriteString("XYZ ")
+	header.Write(make([]byte, 12)) // creation date
+	header.WriteString("acsp")
+	header.Write(make([]byte, 4+4+4+4+8))              // platform, flags, manufacturer, model, attributes
+	binary.Write(&header, binary.BigEndian, uint32(0)) // perceptual intent
+	header.Write(iccXYZNumber(iccWhitePoint))
+	header.Write(make([]byte, headerSize-header.Len()))
+
+	return append(append(header.Bytes(), table.Bytes()...), body.Bytes()...)
+}
+
+// iccS15Fixed16 encodes a signed 15.16 fixed point number
+func iccS15Fixed16(v float64) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(int32(math.Round(v*65536))))
+	return b
+}
+
+func iccXYZNumber(xyz [3]float64) []byte {
+	var b []byte
+	for _, v := range xyz {
+		b = append(b, iccS15Fixed16(v)...)
+	}
+	return b
+}
+
+// iccXYZ encodes an XYZType tag
+func iccXYZ(xyz [3]float64) []byte {
+	return append([]byte("XYZ \x00\x00\x00\x00"), iccXYZNumber(xyz)...)
+}
+
+// iccCurve encodes a curveType tag holding a single gamma value
+func iccCurve(gamma float64) []byte {
+	b := []byte("curv\x00\x00\x00\x00")
+	b = binary.BigEndian.AppendUint32(b, 1)
+	return binary.BigEndian.AppendUint16(b, uint16(math.Round(gamma*256)))
+}
+
+// iccText encodes a textType tag
+func iccText(s string) []byte {
+	return append([]byte("text\x00\x00\x00\x00"+s), 0)
+}
+
+// iccTextDescription encodes a textDescriptionType tag with an ASCII
+// description and empty Unicode and ScriptCode descriptions
+func iccTextDescription(s string) []byte {
+	b := []byte("desc\x00\x00\x00\x00")
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)+1))
+	b = append(append(b, s...), 0)
+	b = append(b, make([]byte, 4+4)...)    // Unicode language and count
+	b = append(b, make([]byte, 2+1+67)...) // ScriptCode code, count and description
+	return b
+}
@@ -0,0 +1,103 @@
+package to_pdf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"main/card_error"
+	"main/card_image"
+	"main/data"
+	"main/fetcher"
+	"sync"
+)
+
+// inlineImages turns the source of every image card into a data URI typed
+// from the image bytes. URL sources are downloaded through f, so the renderers
+// never fetch remote content on their own; base64 and data URI sources are
+// re-encoded with their sniffed media type. Every image is checked against
+// budget, as the renderers decode them in full. Cards whose image cannot be
+// fetched, is not a known format or is too big are left out and returned as
+// failed, in card order; going over the request budget fails with
+// card_image.ErrRequestLimit.
+func inlineImages(ctx context.Context, f *fetcher.Fetcher, budget *card_image.Budget, idCardsResp data.IdCardsResponseSchema) (data.IdCardsResponseSchema, []*card_error.CardError, error) {
+	cards := make([]data.IdCard, len(idCardsResp.Data))
+	copy(cards, idCardsResp.Data)
+	keep := make([]bool, len(cards))
+	errs := make([]*card_error.CardError, len(cards))
+
+	var wg sync.WaitGroup
+	for i, card := range cards {
+		if card.Attributes.Type == data.IdCardAttributesTypeHTML {
+			keep[i] = true
+			continue
+		}
+		if !isURL(card.Attributes.Source) {
+			uri, err := sourceDataURI(budget, card.Attributes.Source)
+			if err != nil {
+				log.Printf("Failed to decode image for card %s: %v", card.Id, err)
+				errs[i] = &card_error.CardError{CardId: card.Id, Stage: card_error.StageDecode, Err: err}
+				continue
+			}
+			cards[i].Attributes.Source = uri
+			keep[i] = true
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, card data.IdCard) {
+			defer wg.Done()
+			result, err := f.Fetch(ctx, card.Attributes.Source)
+			if err != nil {
+				log.Printf("Failed to fetch image for card %s: %v", card.Id, err)
+				errs[i] = &card_error.CardError{CardId: card.Id, Stage: card_error.StageFetch, Err: err}
+				return
+			}
+			uri, err := checkedDataURI(budget, result.Body)
+			if err != nil {
+				log.Printf("Failed to decode image for card %s: %v", card.Id, err)
+				errs[i] = &card_error.CardError{CardId: card.Id, Stage: card_error.StageDecode, Err: err}
+				return
+			}
+			cards[i].Attributes.Source = uri
+			keep[i] = true
+		}(i, card)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return idCardsResp, nil, fmt.Errorf("fetching card images aborted: %w", err)
+	}
+
+	inlined := data.IdCardsResponseSchema{Data: make([]data.IdCard, 0, len(cards))}
+	var failed []*card_error.CardError
+	for i, card := range cards {
+		if keep[i] {
+			inlined.Data = append(inlined.Data, card)
+			continue
+		}
+		if errors.Is(errs[i].Err, card_image.ErrRequestLimit) {
+			return idCardsResp, nil, errs[i].Err
+		}
+		failed = append(failed, errs[i])
+	}
+	return inlined, failed, nil
+}
+
+// sourceDataURI re-encodes a base64 or data URI card source as a data URI with
+// its sniffed media type
+func sourceDataURI(budget *card_image.Budget, source string) (string, error) {
+	content, _, err := card_image.DecodeSource(source)
+	if err != nil {
+		return "", err
+	}
+	return checkedDataURI(budget, content)
+}
+
+// checkedDataURI charges content to budget and encodes it as a data URI
+func checkedDataURI(budget *card_image.Budget, content []byte) (string, error) {
+	if _, err := budget.Check(content); err != nil {
+		return "", err
+	}
+	return card_image.DataURI(content)
+}
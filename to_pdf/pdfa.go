@@ -0,0 +1,306 @@
+package to_pdf
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"main/data"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// XMP namespace of the ID card properties recorded in archival PDFs
+const (
+	idCardNamespace = "http://ns.idcards.example/pdfa/1.0/"
+	idCardPrefix    = "idcard"
+)
+
+// archiveMetadata is recorded in the XMP metadata and document information
+// dictionary of an archival PDF
+type archiveMetadata struct {
+	Title     string
+	Producer  string
+	Creator   string
+	IssueDate time.Time
+	MemberID  string
+	CardIDs   []string
+}
+
+// xmpDate formats a date as an XMP date
+func xmpDate(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+var xmpTemplate = template.Must(template.New("xmp").Funcs(template.FuncMap{
+	"xml": func(s string) (string, error) {
+		var sb strings.Builder
+		err := xml.EscapeText(&sb, []byte(s))
+		return sb.String(), err
+	},
+	"date":      xmpDate,
+	"namespace": func() string { return idCardNamespace },
+	"prefix":    func() string { return idCardPrefix },
+}).Parse(`<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about=""
+ xmlns:dc="http://purl.org/dc/elements/1.1/"
+ xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+ xmlns:pdf="http://ns.adobe.com/pdf/1.3/"
+ xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/"
+ xmlns:pdfaExtension="http://www.aiim.org/pdfa/ns/extension/"
+ xmlns:pdfaSchema="http://www.aiim.org/pdfa/ns/schema#"
+ xmlns:pdfaProperty="http://www.aiim.org/pdfa/ns/property#"
+ xmlns:{{prefix}}="{{namespace}}">
+<pdfaid:part>2</pdfaid:part>
+<pdfaid:conformance>B</pdfaid:conformance>
+<dc:format>application/pdf</dc:format>
+<dc:title><rdf:Alt><rdf:li xml:lang="x-default">{{xml .Title}}</rdf:li></rdf:Alt></dc:title>
+{{- with .Producer}}
+<pdf:Producer>{{xml .}}</pdf:Producer>
+{{- end}}
+{{- with .Creator}}
+<xmp:CreatorTool>{{xml .}}</xmp:CreatorTool>
+{{- end}}
+<xmp:CreateDate>{{date .IssueDate}}</xmp:CreateDate>
+<xmp:ModifyDate>{{date .IssueDate}}</xmp:ModifyDate>
+<xmp:MetadataDate>{{date .IssueDate}}</xmp:MetadataDate>
+<{{prefix}}:IssueDate>{{date .IssueDate}}</{{prefix}}:IssueDate>
+{{- with .MemberID}}
+<{{prefix}}:MemberID>{{xml .}}</{{prefix}}:MemberID>
+{{- end}}
+<{{prefix}}:CardIDs><rdf:Bag>{{range .CardIDs}}<rdf:li>{{xml .}}</rdf:li>{{end}}</rdf:Bag></{{prefix}}:CardIDs>
+<pdfaExtension:schemas><rdf:Bag><rdf:li rdf:parseType="Resource">
+<pdfaSchema:schema>ID card</pdfaSchema:schema>
+<pdfaSchema:namespaceURI>{{namespace}}</pdfaSchema:namespaceURI>
+<pdfaSchema:prefix>{{prefix}}</pdfaSchema:prefix>
+<pdfaSchema:property><rdf:Seq>
+<rdf:li rdf:parseType="Resource"><pdfaProperty:name>IssueDate</pdfaProperty:name><pdfaProperty:valueType>Date</pdfaProperty:valueType><pdfaProperty:category>external</pdfaProperty:category><pdfaProperty:description>When the ID cards were issued</pdfaProperty:description></rdf:li>
+<rdf:li rdf:parseType="Resource"><pdfaProperty:name>MemberID</pdfaProperty:name><pdfaProperty:valueType>Text</pdfaProperty:valueType><pdfaProperty:category>external</pdfaProperty:category><pdfaProperty:description>Member the ID cards belong to</pdfaProperty:description></rdf:li>
+<rdf:li rdf:parseType="Resource"><pdfaProperty:name>CardIDs</pdfaProperty:name><pdfaProperty:valueType>bag Text</pdfaProperty:valueType><pdfaProperty:category>external</pdfaProperty:category><pdfaProperty:description>Ids of the ID card faces in the document</pdfaProperty:description></rdf:li>
+</rdf:Seq></pdfaSchema:property>
+</rdf:li></rdf:Bag></pdfaExtension:schemas>
+</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`))
+
+// archive converts a rendered PDF to PDF/A-2b and checks the result
+func archive(pdf []byte, doc documentData, idCardsResp data.IdCardsResponseSchema, issued time.Time) ([]byte, error) {
+	meta := archiveMetadata{Title: doc.Title, IssueDate: issued}
+	meta.MemberID, _ = memberID(idCardsResp)
+	for _, card := range idCardsResp.Data {
+		meta.CardIDs = append(meta.CardIDs, card.Id)
+	}
+
+	pdf, err := convertToPDFA(pdf, meta)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckPDFAMarkers(pdf); err != nil {
+		return nil, err
+	}
+	return pdf, nil
+}
+
+// readPDF parses a PDF with pdfcpu
+func readPDF(pdf []byte) (*model.Context, error) {
+	disableConfigDir.Do(api.DisableConfigDir)
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	return api.ReadContext(bytes.NewReader(pdf), conf)
+}
+
+// convertToPDFA turns a rendered PDF into a PDF/A-2b file. The renderers
+// already embed every font and only reference data URIs, so what is missing is
+// the sRGB output intent, the XMP metadata and a matching document
+// information dictionary. They are appended as an incremental update so the
+// rendered objects, including their tagged structure, stay untouched.
+func convertToPDFA(pdf []byte, meta archiveMetadata) ([]byte, error) {
+	ctx, err := readPDF(pdf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	if ctx.Encrypt != nil {
+		return nil, fmt.Errorf("%w: encrypted", ErrNotPDFA)
+	}
+	root, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.Write.Increment = true
+	ctx.Write.Offset = ctx.Read.FileSize
+	ctx.WriteXRefStream = ctx.Read.UsingXRefStreams
+	ctx.WriteObjectStream = false
+
+	addObject := func(obj types.Object) (*types.IndirectRef, error) {
+		ref, err := ctx.IndRefForNewObject(obj)
+		if err != nil {
+			return nil, err
+		}
+		ctx.Write.IncrementWithObjNr(ref.ObjectNumber.Value())
+		return ref, nil
+	}
+
+	for _, objNr := range fixRenderedObjects(ctx) {
+		ctx.Write.IncrementWithObjNr(objNr)
+	}
+
+	// Keep what the renderer says about itself
+	if info, err := infoDict(ctx); err == nil && info != nil {
+		if meta.Producer == "" {
+			meta.Producer = infoString(info, "Producer")
+		}
+		if meta.Creator == "" {
+			meta.Creator = infoString(info, "Creator")
+		}
+	}
+	meta.IssueDate = meta.IssueDate.Truncate(time.Second)
+
+	profile, err := ctx.NewStreamDictForBuf(sRGBProfile)
+	if err != nil {
+		return nil, err
+	}
+	profile.InsertInt("N", 3)
+	if err := profile.Encode(); err != nil {
+		return nil, err
+	}
+	profileRef, err := addObject(*profile)
+	if err != nil {
+		return nil, err
+	}
+
+	var xmp bytes.Buffer
+	if err := xmpTemplate.Execute(&xmp, meta); err != nil {
+		return nil, fmt.Errorf("failed to build XMP metadata: %w", err)
+	}
+	// Metadata stays uncompressed so it can be found without a PDF parser
+	metadata := types.StreamDict{Dict: types.NewDict(), Content: xmp.Bytes()}
+	metadata.InsertName("Type", "Metadata")
+	metadata.InsertName("Subtype", "XML")
+	if err := metadata.Encode(); err != nil {
+		return nil, err
+	}
+	metadataRef, err := addObject(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	intent := types.NewDict()
+	intent.InsertName("Type", "OutputIntent")
+	intent.InsertName("S", "GTS_PDFA1")
+	intent.Insert("OutputConditionIdentifier", pdfTextString("sRGB"))
+	intent.Insert("Info", pdfTextString(sRGBProfileDescription))
+	intent.Insert("DestOutputProfile", *profileRef)
+
+	root.Update("Metadata", *metadataRef)
+	root.Update("OutputIntents", types.Array{intent})
+	ctx.Write.IncrementWithObjNr(ctx.Root.ObjectNumber.Value())
+
+	// The information dictionary has to agree with the XMP metadata
+	date := types.StringLiteral(types.DateString(meta.IssueDate.UTC()))
+	info := types.NewDict()
+	info.Insert("Title", pdfTextString(meta.Title))
+	if meta.Producer != "" {
+		info.Insert("Producer", pdfTextString(meta.Producer))
+	}
+	if meta.Creator != "" {
+		info.Insert("Creator", pdfTextString(meta.Creator))
+	}
+	info.Insert("CreationDate", date)
+	info.Insert("ModDate", date)
+	if ctx.Info, err = addObject(info); err != nil {
+		return nil, err
+	}
+
+	if len(ctx.ID) == 0 {
+		sum := md5.Sum(pdf)
+		id := types.NewHexLiteral(sum[:])
+		ctx.ID = types.Array{id, id}
+	}
+
+	out := bytes.NewBuffer(append(make([]byte, 0, len(pdf)+xmp.Len()+len(sRGBProfile)), pdf...))
+	if err := api.WriteIncrement(ctx, out); err != nil {
+		return nil, fmt.Errorf("failed to write PDF/A metadata: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// fixRenderedObjects clears what renderers emit but PDF/A-2 forbids: Chrome
+// asks for smoothed image scaling and link annotations are not always marked
+// for printing. It returns the numbers of the changed objects.
+func fixRenderedObjects(ctx *model.Context) []int {
+	var changed []int
+	for objNr, entry := range ctx.Table {
+		if entry == nil || entry.Free {
+			continue
+		}
+		switch obj := entry.Object.(type) {
+		case types.StreamDict:
+			if s := obj.Subtype(); s != nil && *s == "Image" && obj.BooleanEntry("Interpolate") != nil {
+				obj.Delete("Interpolate")
+				changed = append(changed, objNr)
+			}
+		case types.Dict:
+			s := obj.Subtype()
+			if s == nil || *s == "Popup" || obj["Rect"] == nil {
+				continue
+			}
+			flags := 0
+			if f := obj.IntEntry("F"); f != nil {
+				flags = *f
+			}
+			if fixed := (flags | annotPrint) &^ (annotInvisible | annotHidden | annotNoView); fixed != flags {
+				obj.Update("F", types.Integer(fixed))
+				changed = append(changed, objNr)
+			}
+		}
+	}
+	return changed
+}
+
+// infoDict returns the document information dictionary, or nil
+func infoDict(ctx *model.Context) (types.Dict, error) {
+	if ctx.Info == nil {
+		return nil, nil
+	}
+	return ctx.DereferenceDict(*ctx.Info)
+}
+
+// infoString returns a text string of the document information dictionary
+func infoString(info types.Dict, key string) string {
+	obj, ok := info.Find(key)
+	if !ok {
+		return ""
+	}
+	s, err := types.StringOrHexLiteral(obj)
+	if err != nil || s == nil {
+		return ""
+	}
+	return *s
+}
+
+// pdfTextString encodes s as a PDF text string, in UTF-16 unless it is
+// printable ASCII
+func pdfTextString(s string) types.Object {
+	encode := types.Escape
+	for _, r := range s {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			encode = types.EscapeUTF16String
+			break
+		}
+	}
+	escaped, err := encode(s)
+	if err != nil {
+		return types.StringLiteral("")
+	}
+	return types.StringLiteral(*escaped)
+}
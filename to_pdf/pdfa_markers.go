@@ -0,0 +1,398 @@
+package to_pdf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// ErrNotPDFA is wrapped by CheckPDFAMarkers and VeraPDF.Validate errors
+var ErrNotPDFA = errors.New("not PDF/A-2b conformant")
+
+// XMP namespaces checked by CheckPDFAMarkers
+const (
+	nsRDF    = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	nsDC     = "http://purl.org/dc/elements/1.1/"
+	nsXMP    = "http://ns.adobe.com/xap/1.0/"
+	nsPDF    = "http://ns.adobe.com/pdf/1.3/"
+	nsPDFAID = "http://www.aiim.org/pdfa/ns/id/"
+)
+
+// pdfHeader matches the file header
+var pdfHeader = regexp.MustCompile(`^%PDF-1\.[0-7][\r\n]`)
+
+// hasBinaryComment reports whether the header is followed by the comment of
+// at least four bytes above 127 that marks the file as binary
+func hasBinaryComment(pdf []byte) bool {
+	line := pdf[bytes.IndexAny(pdf, "\r\n")+1:]
+	line = bytes.TrimLeft(line, "\r\n")
+	if len(line) < 5 || line[0] != '%' {
+		return false
+	}
+	for _, b := range line[1:5] {
+		if b < 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// Actions and annotations PDF/A-2 forbids because they run code, play media
+// or reach outside the file
+var (
+	forbiddenActions = map[string]bool{
+		"Launch": true, "Sound": true, "Movie": true, "ResetForm": true, "ImportData": true,
+		"Hide": true, "SetOCGState": true, "Rendition": true, "Trans": true, "GoTo3DView": true,
+		"JavaScript": true,
+	}
+	forbiddenAnnotations = map[string]bool{
+		"3D": true, "Sound": true, "Screen": true, "Movie": true, "FileAttachment": true,
+	}
+)
+
+// Annotation flags
+const (
+	annotInvisible = 1 << 0
+	annotHidden    = 1 << 1
+	annotPrint     = 1 << 2
+	annotNoView    = 1 << 5
+)
+
+// CheckPDFAMarkers is a subset check of PDF/A-2b, not a validator: it looks
+// for the markers an archival ID card PDF needs and a renderer or a theme can
+// break. These are the file structure, no encryption, an output intent with an
+// embedded ICC profile, XMP metadata identifying PDF/A-2 and agreeing with the
+// document information dictionary, embedded fonts and no code, media or
+// references outside the file. It does not look at content streams, colour
+// spaces, transparency or font internals, so passing it does not prove
+// conformance; the verapdf build tag runs veraPDF for that.
+func CheckPDFAMarkers(pdf []byte) error {
+	var v validation
+	if !pdfHeader.Match(pdf) || !hasBinaryComment(pdf) {
+		v.fail("missing PDF 1.x header with binary comment")
+	}
+
+	ctx, err := readPDF(pdf)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNotPDFA, err)
+	}
+	if ctx.Encrypt != nil {
+		v.fail("encrypted")
+	}
+	if len(ctx.ID) != 2 {
+		v.fail("trailer has no file ID")
+	}
+
+	root, err := ctx.Catalog()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNotPDFA, err)
+	}
+	if b := root.BooleanEntry("NeedsRendering"); b != nil && *b {
+		v.fail("catalog needs rendering")
+	}
+	v.checkOutputIntents(ctx, root)
+	xmp := v.checkMetadata(ctx, root)
+	if xmp != nil {
+		v.checkInfo(ctx, xmp)
+	}
+
+	objNrs := make([]int, 0, len(ctx.Table))
+	for objNr := range ctx.Table {
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+	for _, objNr := range objNrs {
+		entry := ctx.Table[objNr]
+		if entry == nil || entry.Free {
+			continue
+		}
+		switch obj := entry.Object.(type) {
+		case types.Dict:
+			v.checkDict(ctx, objNr, obj, false)
+		case types.StreamDict:
+			v.checkDict(ctx, objNr, obj.Dict, true)
+		}
+	}
+
+	return v.err()
+}
+
+// validation collects the missing markers found by CheckPDFAMarkers
+type validation struct {
+	violations []string
+}
+
+func (v *validation) fail(format string, args ...any) {
+	v.violations = append(v.violations, fmt.Sprintf(format, args...))
+}
+
+func (v *validation) err() error {
+	if len(v.violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrNotPDFA, strings.Join(v.violations, "; "))
+}
+
+// checkOutputIntents requires a PDF/A output intent with an embedded ICC profile
+func (v *validation) checkOutputIntents(ctx *model.Context, root types.Dict) {
+	intents, err := ctx.DereferenceArray(root["OutputIntents"])
+	if err != nil || intents == nil {
+		v.fail("no output intent")
+		return
+	}
+	for _, obj := range intents {
+		intent, err := ctx.DereferenceDict(obj)
+		if err != nil || intent == nil {
+			continue
+		}
+		if s := intent.NameEntry("S"); s == nil || *s != "GTS_PDFA1" {
+			continue
+		}
+		profile, _, err := ctx.DereferenceStreamDict(intent["DestOutputProfile"])
+		if err != nil || profile == nil {
+			v.fail("output intent has no ICC profile")
+			return
+		}
+		if err := profile.Decode(); err != nil {
+			v.fail("unreadable ICC profile: %v", err)
+			return
+		}
+		n := profile.IntEntry("N")
+		if n == nil || len(profile.Content) < 40 || string(profile.Content[36:40]) != "acsp" {
+			v.fail("invalid ICC profile")
+			return
+		}
+		if space := iccColorSpaces[*n]; space == "" || string(profile.Content[16:20]) != space {
+			v.fail("ICC profile does not have %d components", *n)
+		}
+		return
+	}
+	v.fail("no GTS_PDFA1 output intent")
+}
+
+// iccColorSpaces are the ICC colour space signatures by component count
+var iccColorSpaces = map[int]string{1: "GRAY", 3: "RGB ", 4: "CMYK"}
+
+// xmpProperties are the simple XMP properties by namespace and name
+type xmpProperties map[xml.Name]string
+
+// checkMetadata requires XMP metadata claiming PDF/A-2 conformance and
+// returns its properties
+func (v *validation) checkMetadata(ctx *model.Context, root types.Dict) xmpProperties {
+	sd, _, err := ctx.DereferenceStreamDict(root["Metadata"])
+	if err != nil || sd == nil {
+		v.fail("no XMP metadata")
+		return nil
+	}
+	if t := sd.Subtype(); t == nil || *t != "XML" {
+		v.fail("metadata is not XML")
+	}
+	if err := sd.Decode(); err != nil {
+		v.fail("unreadable XMP metadata: %v", err)
+		return nil
+	}
+	props, err := parseXMP(sd.Content)
+	if err != nil {
+		v.fail("invalid XMP metadata: %v", err)
+		return nil
+	}
+	if part := props[xml.Name{Space: nsPDFAID, Local: "part"}]; part != "2" {
+		v.fail("XMP metadata claims PDF/A part %q, want 2", part)
+	}
+	switch conformance := props[xml.Name{Space: nsPDFAID, Local: "conformance"}]; conformance {
+	case "A", "B", "U":
+		// A and U are stricter than B
+	default:
+		v.fail("XMP metadata claims conformance level %q", conformance)
+	}
+	return props
+}
+
+// infoProperties pair the document information entries with the XMP
+// properties they must agree with
+var infoProperties = []struct {
+	key  string
+	prop xml.Name
+	date bool
+}{
+	{"Title", xml.Name{Space: nsDC, Local: "title"}, false},
+	{"Author", xml.Name{Space: nsDC, Local: "creator"}, false},
+	{"Subject", xml.Name{Space: nsDC, Local: "description"}, false},
+	{"Keywords", xml.Name{Space: nsPDF, Local: "Keywords"}, false},
+	{"Creator", xml.Name{Space: nsXMP, Local: "CreatorTool"}, false},
+	{"Producer", xml.Name{Space: nsPDF, Local: "Producer"}, false},
+	{"CreationDate", xml.Name{Space: nsXMP, Local: "CreateDate"}, true},
+	{"ModDate", xml.Name{Space: nsXMP, Local: "ModifyDate"}, true},
+}
+
+// checkInfo requires the document information dictionary to agree with the
+// XMP metadata
+func (v *validation) checkInfo(ctx *model.Context, xmp xmpProperties) {
+	info, err := infoDict(ctx)
+	if err != nil || info == nil {
+		return
+	}
+	for _, p := range infoProperties {
+		if _, ok := info.Find(p.key); !ok {
+			continue
+		}
+		value := infoString(info, p.key)
+		prop, ok := xmp[p.prop]
+		if !ok {
+			v.fail("%s is missing from the XMP metadata", p.key)
+			continue
+		}
+		if p.date {
+			infoDate, ok1 := types.DateTime(value, true)
+			xmpTime, err := time.Parse(time.RFC3339, prop)
+			if !ok1 || err != nil || !infoDate.Equal(xmpTime) {
+				v.fail("%s %q does not match the XMP metadata %q", p.key, value, prop)
+			}
+		} else if value != prop {
+			v.fail("%s %q does not match the XMP metadata %q", p.key, value, prop)
+		}
+	}
+}
+
+// checkDict applies the per object rules to a dictionary
+func (v *validation) checkDict(ctx *model.Context, objNr int, d types.Dict, stream bool) {
+	typ, subtype := d.Type(), d.Subtype()
+	is := func(s *string, want string) bool { return s != nil && *s == want }
+
+	if _, ok := d.Find("JS"); ok {
+		v.fail("object %d contains JavaScript", objNr)
+	}
+	if s := d.NameEntry("S"); s != nil && forbiddenActions[*s] {
+		v.fail("object %d is a %s action", objNr, *s)
+	}
+	if _, ok := d.Find("AA"); ok {
+		v.fail("object %d has additional actions", objNr)
+	}
+	for _, key := range []string{"JavaScript", "EmbeddedFiles"} {
+		if _, ok := d.Find(key); ok && !stream {
+			v.fail("object %d has a %s name tree", objNr, key)
+		}
+	}
+	if is(typ, "Filespec") || (stream && d["F"] != nil) {
+		v.fail("object %d references an external file", objNr)
+	}
+	if _, ok := d.Find("OPI"); ok {
+		v.fail("object %d has OPI information", objNr)
+	}
+	if _, ok := d.Find("TR"); ok {
+		v.fail("object %d has a transfer function", objNr)
+	}
+
+	switch {
+	case is(typ, "Font"):
+		v.checkFont(ctx, objNr, d)
+	case is(typ, "Annot") || (subtype != nil && d["Rect"] != nil && !stream):
+		v.checkAnnotation(objNr, d)
+	case stream && is(subtype, "Image"):
+		if b := d.BooleanEntry("Interpolate"); b != nil && *b {
+			v.fail("image %d is interpolated", objNr)
+		}
+		if _, ok := d.Find("Alternates"); ok {
+			v.fail("image %d has alternates", objNr)
+		}
+	case stream && is(subtype, "Form"):
+		if _, ok := d.Find("Ref"); ok {
+			v.fail("form %d is a reference XObject", objNr)
+		}
+	case stream && is(subtype, "PS"):
+		v.fail("object %d is a PostScript XObject", objNr)
+	}
+}
+
+// checkFont requires every font but Type 3 and composite fonts, whose glyphs
+// live in their descendants, to embed its font program
+func (v *validation) checkFont(ctx *model.Context, objNr int, font types.Dict) {
+	if s := font.Subtype(); s != nil && (*s == "Type3" || *s == "Type0") {
+		return
+	}
+	name := "font"
+	if base := font.NameEntry("BaseFont"); base != nil {
+		name = *base
+	}
+	descriptor, err := ctx.DereferenceDict(font["FontDescriptor"])
+	if err != nil || descriptor == nil {
+		v.fail("%s (object %d) is not embedded", name, objNr)
+		return
+	}
+	for _, key := range []string{"FontFile", "FontFile2", "FontFile3"} {
+		if _, ok := descriptor.Find(key); ok {
+			return
+		}
+	}
+	v.fail("%s (object %d) is not embedded", name, objNr)
+}
+
+// checkAnnotation requires annotations to be printed and visible
+func (v *validation) checkAnnotation(objNr int, annot types.Dict) {
+	subtype := *annot.Subtype()
+	if forbiddenAnnotations[subtype] {
+		v.fail("object %d is a %s annotation", objNr, subtype)
+		return
+	}
+	if subtype == "Popup" {
+		return
+	}
+	flags := 0
+	if f := annot.IntEntry("F"); f != nil {
+		flags = *f
+	}
+	if flags&annotPrint == 0 || flags&(annotInvisible|annotHidden|annotNoView) != 0 {
+		v.fail("%s annotation %d is not printed", subtype, objNr)
+	}
+}
+
+// parseXMP collects the simple properties of an XMP packet. The text of
+// rdf:Alt, rdf:Bag and rdf:Seq items is attributed to the enclosing property.
+func parseXMP(packet []byte) (xmpProperties, error) {
+	props := xmpProperties{}
+	dec := xml.NewDecoder(bytes.NewReader(packet))
+	var stack []xml.Name
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return props, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name == (xml.Name{Space: nsRDF, Local: "Description"}) {
+				// Properties can be abbreviated as attributes
+				for _, attr := range t.Attr {
+					if attr.Name.Space != nsRDF && attr.Name.Space != "xmlns" && attr.Name.Space != "" {
+						props[attr.Name] = attr.Value
+					}
+				}
+			}
+			stack = append(stack, t.Name)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			text := strings.TrimSpace(string(t))
+			if text == "" {
+				continue
+			}
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].Space != nsRDF {
+					props[stack[i]] += text
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package to_pdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"main/data"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+func TestGeneratePDFArchival(t *testing.T) {
+	idCardsResp := data.IdCardsResponseSchema{
+		Data: []data.IdCard{data.MockImageIdCardFront, data.MockImageIdCardBack},
+	}
+	opts := Options{Renderer: pdfRenderer{pdf: minimalPDF()}, Archival: true, Title: "Dental cards"}
+
+	resp, err := GeneratePDF(context.Background(), idCardsResp, opts)
+	if err != nil {
+		t.Fatalf("GeneratePDF() error = %v", err)
+	}
+	if err := CheckPDFAMarkers(resp.PDFContent); err != nil {
+		t.Errorf("CheckPDFAMarkers() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">Dental cards</rdf:li>",
+		"<idcard:MemberID>123456789</idcard:MemberID>",
+		"<rdf:li>" + data.MockImageIdCardFront.Id + "</rdf:li><rdf:li>" + data.MockImageIdCardBack.Id + "</rdf:li>",
+	} {
+		if !bytes.Contains(resp.PDFContent, []byte(want)) {
+			t.Errorf("XMP metadata is missing %s", want)
+		}
+	}
+
+	// The incremental update has to be readable by other PDF libraries too
+	doc, err := fitz.NewFromMemory(resp.PDFContent)
+	if err != nil {
+		t.Fatalf("fitz.NewFromMemory() error = %v", err)
+	}
+	defer doc.Close()
+	if n := doc.NumPage(); n != 1 {
+		t.Errorf("NumPage() = %d, want 1", n)
+	}
+}
+
+func TestGeneratePDFArchivalEncryption(t *testing.T) {
+	opts := Options{Renderer: &fakeRenderer{}, Archival: true, Encryption: &Encryption{}}
+	_, err := GeneratePDF(context.Background(), data.IdCardsResponseSchema{}, opts)
+	if !errors.Is(err, ErrArchivalEncryption) {
+		t.Errorf("GeneratePDF() error = %v, want ErrArchivalEncryption", err)
+	}
+}
+
+func TestConvertToPDFAFixesImages(t *testing.T) {
+	pdf := testPDF("<< /XObject << /Im1 5 0 R >> >>",
+		"<< /Type /XObject /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Interpolate true /Length 3 >>\nstream\n\x00\x00\x00\nendstream")
+
+	converted, err := convertToPDFA(pdf, archiveMetadata{Title: "ID Cards", IssueDate: time.Now()})
+	if err != nil {
+		t.Fatalf("convertToPDFA() error = %v", err)
+	}
+	if err := CheckPDFAMarkers(converted); err != nil {
+		t.Errorf("CheckPDFAMarkers() error = %v", err)
+	}
+}
+
+func TestCheckPDFAMarkersRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		pdf  []byte
+		want string
+	}{
+		{
+			name: "font not embedded",
+			pdf: testPDF("<< /Font << /F1 5 0 R >> >>",
+				"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"),
+			want: "Helvetica (object 5) is not embedded",
+		},
+		{
+			name: "JavaScript",
+			pdf: testPDF("<< >>",
+				"<< /Type /Action /S /JavaScript /JS (app.alert\\(1\\)) >>"),
+			want: "contains JavaScript",
+		},
+		{
+			name: "external file",
+			pdf: testPDF("<< >>",
+				"<< /Type /Filespec /F (card.png) >>"),
+			want: "references an external file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converted, err := convertToPDFA(tt.pdf, archiveMetadata{Title: "ID Cards", IssueDate: time.Now()})
+			if err != nil {
+				t.Fatalf("convertToPDFA() error = %v", err)
+			}
+			err = CheckPDFAMarkers(converted)
+			if !errors.Is(err, ErrNotPDFA) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("CheckPDFAMarkers() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// A PDF straight from a renderer lacks the metadata and output intent
+	err := CheckPDFAMarkers(minimalPDF())
+	for _, want := range []string{"no output intent", "no XMP metadata"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("CheckPDFAMarkers() error = %v, want %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package to_pdf
+
+import (
+	"main/data"
+)
+
+// ISO/IEC 7810 ID-1 (CR80) card size and print grid geometry, in millimetres
+const (
+	cr80WidthMM  = 85.60
+	cr80HeightMM = 53.98
+
+	printColumns     = 2
+	printRows        = 4
+	printGutterXMM   = 10.0
+	printGutterYMM   = 8.0
+	cutMarkLengthMM  = 4.0
+	cutMarkOffsetMM  = 1.5
+	cutMarkStrokeMM  = 0.2
+	printSheetSafety = 0.5 // shave off the sheet height so rounding never spills onto a blank page
+)
+
+// printPage is the page used by LayoutPrint: borderless Letter, the grid is
+// positioned by the HTML itself
+var printPage = Page{Width: letterWidthMM, Height: letterHeightMM, Exact: true}
+
+// printData is the data of the "print-page" template
+type printData struct {
+	documentData
+	SheetWidth, SheetHeight float64
+	CardWidth, CardHeight   float64
+	Sheets                  [][]printCard
+}
+
+// printCard is a card face placed on a sheet, positions are in millimetres
+type printCard struct {
+	X, Y     float64
+	Card     *cardView
+	CutMarks []cutMark
+}
+
+// cutMark is a crop mark line, positions and sizes are in millimetres
+type cutMark struct {
+	Left, Top, Width, Height float64
+}
+
+// buildPrintHTML lays the card faces out at real CR80 size, printColumns by
+// printRows per Letter sheet, with cut marks around every card. The front and
+// back of a card share a row so the two cut-outs can be glued back to back.
+func buildPrintHTML(theme *Theme, doc documentData, idCardsResp data.IdCardsResponseSchema) (string, error) {
+	// Cells of the grid in reading order; -1 leaves a cell empty
+	var cells []int
+	for _, pair := range data.PairIdCards(idCardsResp.Data) {
+		if pair.Combined != -1 {
+			cells = append(cells, pair.Combined, -1)
+			continue
+		}
+		cells = append(cells, pair.Front, pair.Back)
+	}
+
+	gridWidth := printColumns*cr80WidthMM + (printColumns-1)*printGutterXMM
+	gridHeight := printRows*cr80HeightMM + (printRows-1)*printGutterYMM
+	offsetX := (letterWidthMM - gridWidth) / 2
+	offsetY := (letterHeightMM - gridHeight) / 2
+	perSheet := printColumns * printRows
+
+	page := printData{
+		documentData: doc,
+		SheetWidth:   letterWidthMM,
+		SheetHeight:  letterHeightMM - printSheetSafety,
+		CardWidth:    cr80WidthMM,
+		CardHeight:   cr80HeightMM,
+	}
+	for start := 0; start < len(cells); start += perSheet {
+		sheet := []printCard{}
+		for n := 0; n < perSheet && start+n < len(cells); n++ {
+			i := cells[start+n]
+			if i == -1 {
+				continue
+			}
+			x := offsetX + float64(n%printColumns)*(cr80WidthMM+printGutterXMM)
+			y := offsetY + float64(n/printColumns)*(cr80HeightMM+printGutterYMM)
+			sheet = append(sheet, printCard{X: x, Y: y, Card: newCardView(idCardsResp.Data[i], doc.TextLayer), CutMarks: cutMarks(x, y)})
+		}
+		page.Sheets = append(page.Sheets, sheet)
+	}
+
+	return theme.execute(templatePrintPage, page)
+}
+
+// cutMarks returns the crop marks just outside the corners of the card whose
+// top left corner is at x, y
+func cutMarks(x, y float64) []cutMark {
+	var marks []cutMark
+	for _, cornerX := range []float64{x, x + cr80WidthMM} {
+		for _, cornerY := range []float64{y, y + cr80HeightMM} {
+			// Horizontal mark extends away from the card along the cut line
+			hx := cornerX - cutMarkOffsetMM - cutMarkLengthMM
+			if cornerX > x {
+				hx = cornerX + cutMarkOffsetMM
+			}
+			marks = append(marks, cutMark{Left: hx, Top: cornerY - cutMarkStrokeMM/2, Width: cutMarkLengthMM, Height: cutMarkStrokeMM})
+
+			// Vertical mark
+			vy := cornerY - cutMarkOffsetMM - cutMarkLengthMM
+			if cornerY > y {
+				vy = cornerY + cutMarkOffsetMM
+			}
+			marks = append(marks, cutMark{Left: cornerX - cutMarkStrokeMM/2, Top: vy, Width: cutMarkStrokeMM, Height: cutMarkLengthMM})
+		}
+	}
+	return marks
+}
@@ -0,0 +1,50 @@
+package to_pdf
+
+import (
+	"context"
+	"main/data"
+	"strings"
+	"testing"
+)
+
+func TestGeneratePDFPrintLayout(t *testing.T) {
+	renderer := &fakeRenderer{}
+	idCardsResp := data.IdCardsResponseSchema{
+		Data: []data.IdCard{data.MockImageIdCardFront, data.MockImageIdCardBack, data.MockHTMLIdCardBoth},
+	}
+
+	if _, err := GeneratePDF(context.Background(), idCardsResp, Options{Renderer: renderer, Layout: LayoutPrint}); err != nil {
+		t.Fatalf("GeneratePDF() error = %v", err)
+	}
+
+	if renderer.page != printPage {
+		t.Errorf("page = %+v, want %+v", renderer.page, printPage)
+	}
+
+	html := string(renderer.html)
+	if n := strings.Count(html, `class="cr80"`); n != 3 {
+		t.Errorf("got %d CR80 cards, want 3", n)
+	}
+	if n := strings.Count(html, `class="cut-mark"`); n != 3*8 {
+		t.Errorf("got %d cut marks, want %d", n, 3*8)
+	}
+	if !strings.Contains(html, "width: 85.60mm") || !strings.Contains(html, "height: 53.98mm") {
+		t.Error("cards are not sized to CR80")
+	}
+}
+
+func TestBuildPrintHTMLPaginates(t *testing.T) {
+	var cards []data.IdCard
+	for i := 0; i < printRows+1; i++ {
+		cards = append(cards, data.MockIdCardFront, data.MockIdCardBack)
+	}
+
+	html, err := buildPrintHTML(DefaultTheme(), documentData{}, data.IdCardsResponseSchema{Data: cards})
+	if err != nil {
+		t.Fatalf("buildPrintHTML() error = %v", err)
+	}
+
+	if n := strings.Count(html, `<div class="sheet">`); n != 2 {
+		t.Errorf("got %d sheets, want 2", n)
+	}
+}
@@ -0,0 +1,101 @@
+package to_pdf
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Renderer names for the engines registered by this package
+const (
+	RendererWkhtmltopdf = "wkhtmltopdf"
+	RendererChromedp    = "chromedp"
+)
+
+// Renderer converts a complete HTML document into PDF bytes printed on page
+type Renderer interface {
+	Name() string
+	Render(ctx context.Context, html []byte, page Page) ([]byte, error)
+}
+
+// tagger is implemented by renderers that write tagged PDFs: a structure
+// tree screen readers navigate, with the AltText of every card on its figure
+type tagger interface {
+	Tagged() bool
+}
+
+// Tagged reports whether r writes tagged, accessible PDFs
+func Tagged(r Renderer) bool {
+	t, ok := r.(tagger)
+	return ok && t.Tagged()
+}
+
+// Page describes the paper a document is printed on. Sizes are in millimetres.
+type Page struct {
+	Width  float64
+	Height float64
+	Margin float64
+	// Exact disables engine-side shrinking so CSS sizes print at their real size
+	Exact bool
+}
+
+// Letter paper sizes, in millimetres
+const (
+	letterWidthMM  = 215.9
+	letterHeightMM = 279.4
+)
+
+// LetterPage is the default page: US Letter with 40mm margins
+var LetterPage = Page{Width: letterWidthMM, Height: letterHeightMM, Margin: 40}
+
+var (
+	renderersMu sync.RWMutex
+	renderers   = map[string]Renderer{}
+)
+
+func init() {
+	RegisterRenderer(NewWkhtmltopdfRenderer())
+	RegisterRenderer(NewChromedpRenderer())
+}
+
+// RegisterRenderer makes a renderer available by name, replacing any renderer
+// previously registered under the same name
+func RegisterRenderer(r Renderer) {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+	renderers[r.Name()] = r
+}
+
+// GetRenderer returns the renderer registered under name
+func GetRenderer(name string) (Renderer, error) {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+	r, ok := renderers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown PDF renderer %q", name)
+	}
+	return r, nil
+}
+
+// RendererNames returns the names of all registered renderers in sorted order
+func RendererNames() []string {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultRenderer returns the renderer used when none is specified. It is
+// chromedp, as only Chrome writes the tagged PDFs screen readers need.
+func DefaultRenderer() Renderer {
+	r, err := GetRenderer(RendererChromedp)
+	if err != nil {
+		return NewChromedpRenderer()
+	}
+	return r
+}
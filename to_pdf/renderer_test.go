@@ -0,0 +1,315 @@
+package to_pdf
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"html"
+	"image"
+	"image/jpeg"
+	"main/card_error"
+	"main/card_image"
+	"main/data"
+	"main/fetcher"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeRenderer struct {
+	html []byte
+	page Page
+}
+
+func (f *fakeRenderer) Name() string {
+	return "fake"
+}
+
+func (f *fakeRenderer) Render(_ context.Context, html []byte, page Page) ([]byte, error) {
+	f.html = html
+	f.page = page
+	return []byte("%PDF-fake"), nil
+}
+
+func TestGetRenderer(t *testing.T) {
+	for _, name := range []string{RendererWkhtmltopdf, RendererChromedp} {
+		r, err := GetRenderer(name)
+		if err != nil {
+			t.Fatalf("GetRenderer(%q) error = %v", name, err)
+		}
+		if r.Name() != name {
+			t.Errorf("GetRenderer(%q).Name() = %q", name, r.Name())
+		}
+	}
+
+	if _, err := GetRenderer("unknown"); err == nil {
+		t.Error("GetRenderer(\"unknown\") expected error")
+	}
+}
+
+func TestDefaultRendererIsTagged(t *testing.T) {
+	if r := DefaultRenderer(); r.Name() != RendererChromedp || !Tagged(r) {
+		t.Errorf("DefaultRenderer() = %q, want the tagged %q renderer", r.Name(), RendererChromedp)
+	}
+	if Tagged(NewWkhtmltopdfRenderer()) {
+		t.Error("Tagged(wkhtmltopdf) = true, want false")
+	}
+	if Tagged(&fakeRenderer{}) {
+		t.Error("Tagged(fake) = true, want false")
+	}
+}
+
+func TestGeneratePDFUsesRenderer(t *testing.T) {
+	renderer := &fakeRenderer{}
+	idCardsResp := data.IdCardsResponseSchema{
+		Data: []data.IdCard{data.MockImageIdCardFront, data.MockHTMLIdCardBack},
+	}
+
+	got, err := GeneratePDF(context.Background(), idCardsResp, Options{Renderer: renderer})
+	if err != nil {
+		t.Fatalf("GeneratePDF() error = %v", err)
+	}
+
+	if !bytes.Equal(got.PDFContent, []byte("%PDF-fake")) {
+		t.Errorf("GeneratePDF() content = %q", got.PDFContent)
+	}
+	if !strings.HasPrefix(got.FileName, "id_cards_") || !strings.HasSuffix(got.FileName, ".pdf") {
+		t.Errorf("GeneratePDF() generated incorrect filename format: %s", got.FileName)
+	}
+	// The templates write "+" of the base64 data as an entity
+	if !strings.Contains(html.UnescapeString(string(renderer.html)), data.MockImageIdCardFront.Attributes.Source) {
+		t.Error("rendered HTML does not reference the image card source")
+	}
+}
+
+func TestBuildHTMLPairedLayout(t *testing.T) {
+	idCardsResp := data.IdCardsResponseSchema{
+		Data: []data.IdCard{data.MockIdCardBack, data.MockIdCardFront, data.MockHTMLIdCardBoth},
+	}
+
+	html, err := buildHTML(DefaultTheme(), documentData{}, idCardsResp, LayoutPaired)
+	if err != nil {
+		t.Fatalf("buildHTML() error = %v", err)
+	}
+
+	if n := strings.Count(html, `<table class="card-row" role="presentation">`); n != 1 {
+		t.Errorf("buildHTML() has %d card rows, want 1", n)
+	}
+	front := strings.Index(html, data.MockIdCardFront.Attributes.Source)
+	back := strings.Index(html, data.MockIdCardBack.Attributes.Source)
+	if front == -1 || back == -1 || front > back {
+		t.Error("buildHTML() does not put the front before the back")
+	}
+}
+
+func TestBuildHTMLSanitises(t *testing.T) {
+	htmlCard := data.MockHTMLIdCardFront
+	htmlCard.Attributes.Source = `<p onclick="steal()">Member</p><script>alert(1)</script>`
+	imageCard := data.MockImageIdCardFront
+	imageCard.Attributes.AltText = `front" onerror="alert(1)`
+
+	got, err := buildHTML(DefaultTheme(), documentData{}, data.IdCardsResponseSchema{Data: []data.IdCard{htmlCard, imageCard}}, LayoutStacked)
+	if err != nil {
+		t.Fatalf("buildHTML() error = %v", err)
+	}
+
+	for _, unwanted := range []string{"<script", "onclick", `" onerror="`} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("buildHTML() kept %q:\n%s", unwanted, got)
+		}
+	}
+	if !strings.Contains(got, "<p>Member</p>") || !strings.Contains(got, `alt="front&#34; onerror=&#34;alert(1)"`) {
+		t.Errorf("buildHTML() = %s", got)
+	}
+}
+
+type blockingRenderer struct{}
+
+func (blockingRenderer) Name() string {
+	return "blocking"
+}
+
+func (blockingRenderer) Render(ctx context.Context, _ []byte, _ Page) ([]byte, error) {
+	<-ctx.Done()
+	return nil, errors.New("signal: killed")
+}
+
+func TestGeneratePDFTimeout(t *testing.T) {
+	_, err := GeneratePDF(context.Background(), data.IdCardsResponseSchema{}, Options{
+		Renderer: blockingRenderer{},
+		Timeout:  10 * time.Millisecond,
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GeneratePDF() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestGeneratePDFAccessibility(t *testing.T) {
+	renderer := &fakeRenderer{}
+	idCardsResp := data.IdCardsResponseSchema{
+		Data: []data.IdCard{data.MockImageIdCardFront, data.MockHTMLIdCardFront},
+	}
+
+	_, err := GeneratePDF(context.Background(), idCardsResp, Options{Renderer: renderer, Title: "Dental cards", Lang: "fr-CA"})
+	if err != nil {
+		t.Fatalf("GeneratePDF() error = %v", err)
+	}
+
+	got := html.UnescapeString(string(renderer.html))
+	for _, want := range []string{`<html lang="fr-CA">`, `<title>Dental cards</title>`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rendered HTML is missing %s", want)
+		}
+	}
+
+	// Both faces are figures labelled with their AltText, in card order
+	front := strings.Index(got, `alt="`+data.MockImageIdCardFront.Attributes.AltText+`"`)
+	back := strings.Index(got, `role="img" aria-label="`+data.MockHTMLIdCardFront.Attributes.AltText+`"`)
+	if front == -1 || back == -1 {
+		t.Fatal("card figures are not labelled with their AltText")
+	}
+	if front > back {
+		t.Error("card figures are out of reading order")
+	}
+}
+
+func TestGeneratePDFTextLayer(t *testing.T) {
+	idCardsResp := data.IdCardsResponseSchema{
+		Data: []data.IdCard{data.MockImageIdCardFront, data.MockHTMLIdCardFront},
+	}
+
+	for _, layout := range []Layout{LayoutStacked, LayoutPrint} {
+		for _, enabled := range []bool{false, true} {
+			renderer := &fakeRenderer{}
+			opts := Options{Renderer: renderer, Layout: layout, TextLayer: enabled}
+			if _, err := GeneratePDF(context.Background(), idCardsResp, opts); err != nil {
+				t.Fatalf("GeneratePDF() error = %v", err)
+			}
+
+			got := html.UnescapeString(string(renderer.html))
+			// Only the image card gets a text layer, HTML cards already have real text
+			want := 0
+			if enabled {
+				want = 1
+			}
+			if n := strings.Count(got, `<div class="text-layer"`); n != want {
+				t.Errorf("%s, TextLayer = %v: got %d text layers, want %d", layout, enabled, n, want)
+			}
+			altText := strings.TrimSpace(data.MockImageIdCardFront.Attributes.AltText)
+			if enabled && !strings.Contains(got, `aria-hidden="true">`+altText+`</div>`) {
+				t.Errorf("%s: text layer does not hold the card AltText", layout)
+			}
+			// Chrome drops text with zero alpha from the PDF, see TestChromedpTextLayer
+			if strings.Contains(got, "color: transparent") {
+				t.Errorf("%s: text layer is fully transparent", layout)
+			}
+		}
+	}
+}
+
+func TestGeneratePDFSniffsImageTypes(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><rect width="10" height="10"/></svg>`
+	jpegCard := data.MockImageIdCardFront
+	jpegCard.Id = "jpeg-card"
+	var jpegBytes bytes.Buffer
+	if err := jpeg.Encode(&jpegBytes, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	jpegCard.Attributes.Source = base64.StdEncoding.EncodeToString(jpegBytes.Bytes())
+	svgURI := data.MockImageIdCardBack
+	svgURI.Id = "svg-card"
+	svgURI.Attributes.Source = "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte(svg))
+	unknown := data.MockImageIdCardBack
+	unknown.Id = "unknown-card"
+	unknown.Attributes.Source = base64.StdEncoding.EncodeToString([]byte("not an image"))
+
+	renderer := &fakeRenderer{}
+	idCardsResp := data.IdCardsResponseSchema{Data: []data.IdCard{jpegCard, svgURI, unknown}}
+	if _, err := GeneratePDF(context.Background(), idCardsResp, Options{Renderer: renderer}); err != nil {
+		t.Fatalf("GeneratePDF() error = %v", err)
+	}
+
+	got := html.UnescapeString(string(renderer.html))
+	if !strings.Contains(got, `src="data:image/jpeg;base64,`+jpegCard.Attributes.Source+`"`) {
+		t.Error("JPEG card is not typed image/jpeg")
+	}
+	if !strings.Contains(got, `src="data:image/svg+xml;base64,`) {
+		t.Error("SVG card is not typed image/svg+xml")
+	}
+	if strings.Contains(got, unknown.Attributes.Source) {
+		t.Error("card with an unknown image format was rendered")
+	}
+}
+
+func TestGeneratePDFLimits(t *testing.T) {
+	idCardsResp := data.IdCardsResponseSchema{Data: []data.IdCard{data.MockImageIdCardFront, data.MockImageIdCardBack}}
+	content, _, err := card_image.DecodeSource(data.MockImageIdCardFront.Attributes.Source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The HTML card is not an image, so the PDF is still rendered
+	withHTML := data.IdCardsResponseSchema{Data: append([]data.IdCard{data.MockHTMLIdCardFront}, idCardsResp.Data...)}
+	renderer := &fakeRenderer{}
+	opts := Options{Renderer: renderer, Limits: card_image.Limits{MaxCardBytes: int64(len(content)) - 1}}
+	if _, err := GeneratePDF(context.Background(), withHTML, opts); err != nil {
+		t.Fatalf("GeneratePDF() error = %v", err)
+	}
+	if strings.Contains(html.UnescapeString(string(renderer.html)), data.MockImageIdCardFront.Attributes.Source) {
+		t.Error("card over the size limit was rendered")
+	}
+
+	opts = Options{Renderer: &fakeRenderer{}, Limits: card_image.Limits{MaxRequestBytes: int64(len(content))}}
+	if _, err := GeneratePDF(context.Background(), idCardsResp, opts); !errors.Is(err, card_image.ErrRequestLimit) {
+		t.Errorf("GeneratePDF() error = %v, want ErrRequestLimit", err)
+	}
+}
+
+func TestGeneratePDFReportsFailedCards(t *testing.T) {
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	remote := data.MockImageIdCardBack
+	remote.Id = "remote-card"
+	remote.Attributes.Source = missing.URL + "/card.png"
+	unknown := data.MockImageIdCardBack
+	unknown.Id = "unknown-card"
+	unknown.Attributes.Source = base64.StdEncoding.EncodeToString([]byte("not an image"))
+	idCardsResp := data.IdCardsResponseSchema{Data: []data.IdCard{remote, data.MockImageIdCardFront, unknown}}
+	f := fetcher.New(fetcher.Config{AllowPrivateNetworks: true})
+
+	renderer := &fakeRenderer{}
+	resp, err := GeneratePDF(context.Background(), idCardsResp, Options{Renderer: renderer, Fetcher: f})
+	if err != nil {
+		t.Fatalf("GeneratePDF() error = %v", err)
+	}
+	want := []struct {
+		id    string
+		stage card_error.Stage
+	}{{remote.Id, card_error.StageFetch}, {unknown.Id, card_error.StageDecode}}
+	if len(resp.FailedCards) != len(want) {
+		t.Fatalf("FailedCards = %v, want %d cards", resp.FailedCards, len(want))
+	}
+	for i, w := range want {
+		if got := resp.FailedCards[i]; got.CardId != w.id || got.Stage != w.stage {
+			t.Errorf("FailedCards[%d] = %v, want card %s at %s", i, got, w.id, w.stage)
+		}
+	}
+	if strings.Contains(string(renderer.html), missing.URL) {
+		t.Error("card that failed to fetch was rendered")
+	}
+
+	_, err = GeneratePDF(context.Background(), idCardsResp, Options{Renderer: &fakeRenderer{}, Fetcher: f, Strict: true})
+	var cardsErr *card_error.CardsError
+	if !errors.As(err, &cardsErr) || len(cardsErr.Cards) != 2 || cardsErr.Total != 3 {
+		t.Errorf("strict GeneratePDF() error = %v, want a CardsError for 2 of 3 cards", err)
+	}
+
+	onlyRemote := data.IdCardsResponseSchema{Data: []data.IdCard{remote}}
+	if _, err := GeneratePDF(context.Background(), onlyRemote, Options{Renderer: &fakeRenderer{}, Fetcher: f}); !errors.As(err, &cardsErr) {
+		t.Errorf("GeneratePDF() without any card error = %v, want a CardsError", err)
+	}
+}
@@ -0,0 +1,404 @@
+package to_pdf
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/unidoc/pkcs7"
+	"github.com/unidoc/timestamp"
+)
+
+// ErrSignedEncryption is returned when a signed PDF is asked to be encrypted.
+// Encrypting rewrites the file, which would break the signature.
+var ErrSignedEncryption = errors.New("signed PDFs cannot be encrypted")
+
+// DefaultSignatureReason is recorded in signatures when Signer.Reason is empty
+const DefaultSignatureReason = "Issued ID card"
+
+// DefaultTSATimeout bounds a request to the time stamping authority
+const DefaultTSATimeout = 10 * time.Second
+
+// signatureSize is the space reserved for the CMS signature, certificates and
+// timestamp token included
+const signatureSize = 16 << 10
+
+// maxTSAResponse caps the size of a time stamping authority response
+const maxTSAResponse = 1 << 20
+
+// Signer applies the card issuer's PAdES signature to generated PDFs, so
+// providers and pharmacies can check that a card is genuine
+type Signer struct {
+	// Certificate identifies the issuer
+	Certificate *x509.Certificate
+	// Chain are the intermediate certificates from Certificate up to, but not
+	// including, the root
+	Chain []*x509.Certificate
+	// Key is the private key of Certificate
+	Key crypto.Signer
+	// TSAURL is an RFC 3161 time stamping authority that timestamps every
+	// signature; signatures carry no timestamp when empty
+	TSAURL string
+	// HTTPClient calls the TSA; a client bounded by DefaultTSATimeout is used
+	// when nil
+	HTTPClient *http.Client
+	// Reason is shown by PDF viewers; DefaultSignatureReason is used when empty
+	Reason string
+	// Location is shown by PDF viewers when set
+	Location string
+}
+
+// LoadSigner reads a PEM certificate file, whose first certificate is the
+// signing certificate and the others its chain, and a PEM private key file
+func LoadSigner(certFile, keyFile string) (*Signer, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing certificate: %w", err)
+	}
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(certPEM); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signing certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate in %s", certFile)
+	}
+
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no private key in %s", keyFile)
+	}
+	key, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+
+	return &Signer{Certificate: certs[0], Chain: certs[1:], Key: key}, nil
+}
+
+// parsePrivateKey parses a PKCS #8, PKCS #1 or SEC 1 private key
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("unknown key format")
+}
+
+// byteRangePlaceholder reserves room for the final /ByteRange values
+const byteRangePlaceholder = 9999999999
+
+// sign appends a signature field signed by s to the PDF as an incremental
+// update. The signature covers the whole file except its own /Contents.
+func (s *Signer) sign(ctx context.Context, pdf []byte, signedAt time.Time) ([]byte, error) {
+	pdfCtx, err := readPDF(pdf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	if pdfCtx.Encrypt != nil {
+		return nil, ErrSignedEncryption
+	}
+
+	withSignature, err := addSignatureField(pdfCtx, pdf, s.signatureDict(signedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	byteRange, contents, err := placeholders(withSignature, len(pdf))
+	if err != nil {
+		return nil, err
+	}
+	// The byte range is part of the signed bytes, so it is filled in first
+	copy(withSignature[byteRange[0]:byteRange[1]], formatByteRange(
+		[4]int{0, contents[0], contents[1], len(withSignature) - contents[1]}, byteRange[1]-byteRange[0]))
+	signed := append(append([]byte{}, withSignature[:contents[0]]...), withSignature[contents[1]:]...)
+
+	cms, err := s.cms(ctx, signed)
+	if err != nil {
+		return nil, err
+	}
+	if 2*len(cms) > contents[1]-contents[0]-2 {
+		return nil, fmt.Errorf("signature of %d bytes does not fit the %d reserved", len(cms), signatureSize)
+	}
+	copy(withSignature[contents[0]+1:], hex.EncodeToString(cms))
+	return withSignature, nil
+}
+
+// signatureDict is the signature value with placeholders for the byte range
+// and the CMS signature
+func (s *Signer) signatureDict(signedAt time.Time) types.Dict {
+	reason := s.Reason
+	if reason == "" {
+		reason = DefaultSignatureReason
+	}
+	d := types.NewDict()
+	d.InsertName("Type", "Sig")
+	d.InsertName("Filter", "Adobe.PPKLite")
+	d.InsertName("SubFilter", "ETSI.CAdES.detached")
+	d.Insert("ByteRange", types.Array{
+		types.Integer(0), types.Integer(byteRangePlaceholder),
+		types.Integer(byteRangePlaceholder), types.Integer(byteRangePlaceholder),
+	})
+	d.Insert("Contents", types.HexLiteral(bytes.Repeat([]byte("0"), 2*signatureSize)))
+	d.Insert("M", types.StringLiteral(types.DateString(signedAt)))
+	d.Insert("Name", pdfTextString(s.Certificate.Subject.CommonName))
+	d.Insert("Reason", pdfTextString(reason))
+	if s.Location != "" {
+		d.Insert("Location", pdfTextString(s.Location))
+	}
+	return d
+}
+
+// addSignatureField writes an incremental update adding an invisible
+// signature field on the first page whose value is sig
+func addSignatureField(ctx *model.Context, pdf []byte, sig types.Dict) ([]byte, error) {
+	ctx.Write.Increment = true
+	ctx.Write.Offset = ctx.Read.FileSize
+	ctx.WriteXRefStream = ctx.Read.UsingXRefStreams
+	ctx.WriteObjectStream = false
+
+	sigRef, err := ctx.IndRefForNewObject(sig)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Write.IncrementWithObjNr(sigRef.ObjectNumber.Value())
+
+	pageRef, err := ctx.PageDictIndRef(1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find first page: %w", err)
+	}
+	page, err := ctx.DereferenceDict(*pageRef)
+	if err != nil {
+		return nil, err
+	}
+
+	widget := types.NewDict()
+	widget.InsertName("Type", "Annot")
+	widget.InsertName("Subtype", "Widget")
+	widget.InsertName("FT", "Sig")
+	widget.Insert("T", pdfTextString("Issuer signature"))
+	widget.Insert("V", *sigRef)
+	widget.Insert("Rect", types.NewNumberArray(0, 0, 0, 0))
+	widget.InsertInt("F", annotPrint|annotLocked)
+	widget.Insert("P", *pageRef)
+	widgetRef, err := ctx.IndRefForNewObject(widget)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Write.IncrementWithObjNr(widgetRef.ObjectNumber.Value())
+
+	if err := appendToArray(ctx, page, pageRef.ObjectNumber.Value(), "Annots", *widgetRef); err != nil {
+		return nil, err
+	}
+
+	root, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	form, formObjNr, err := dictEntry(ctx, root, ctx.Root.ObjectNumber.Value(), "AcroForm")
+	if err != nil {
+		return nil, err
+	}
+	if err := appendToArray(ctx, form, formObjNr, "Fields", *widgetRef); err != nil {
+		return nil, err
+	}
+	// The document has signatures and must only be changed by appending
+	form.Update("SigFlags", types.Integer(3))
+	ctx.Write.IncrementWithObjNr(formObjNr)
+
+	out := bytes.NewBuffer(append(make([]byte, 0, len(pdf)+4*signatureSize), pdf...))
+	if err := api.WriteIncrement(ctx, out); err != nil {
+		return nil, fmt.Errorf("failed to write signature field: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// annotLocked keeps viewers from moving or deleting an annotation
+const annotLocked = 1 << 7
+
+// dictEntry returns the dictionary d holds under key, adding an empty one if
+// there is none, and the number of the object to rewrite when it changes
+func dictEntry(ctx *model.Context, d types.Dict, objNr int, key string) (types.Dict, int, error) {
+	switch v := d[key].(type) {
+	case types.IndirectRef:
+		entry, err := ctx.DereferenceDict(v)
+		if err != nil || entry == nil {
+			return nil, 0, fmt.Errorf("invalid /%s: %v", key, err)
+		}
+		return entry, v.ObjectNumber.Value(), nil
+	case types.Dict:
+		return v, objNr, nil
+	}
+	entry := types.NewDict()
+	d.Update(key, entry)
+	return entry, objNr, nil
+}
+
+// appendToArray appends value to the array d holds under key and marks the
+// object holding the array for the incremental update
+func appendToArray(ctx *model.Context, d types.Dict, objNr int, key string, value types.Object) error {
+	if ref, ok := d[key].(types.IndirectRef); ok {
+		arr, err := ctx.DereferenceArray(ref)
+		if err != nil {
+			return err
+		}
+		entry, ok := ctx.FindTableEntryForIndRef(&ref)
+		if !ok {
+			return fmt.Errorf("invalid /%s", key)
+		}
+		entry.Object = append(arr, value)
+		ctx.Write.IncrementWithObjNr(ref.ObjectNumber.Value())
+		return nil
+	}
+	arr, err := ctx.DereferenceArray(d[key])
+	if err != nil {
+		return err
+	}
+	d.Update(key, append(arr, value))
+	ctx.Write.IncrementWithObjNr(objNr)
+	return nil
+}
+
+// placeholders finds the /ByteRange array and the /Contents hex string of
+// the signature written after offset
+func placeholders(pdf []byte, offset int) (byteRange, contents [2]int, err error) {
+	zeros := bytes.Repeat([]byte("0"), 2*signatureSize)
+	i := bytes.Index(pdf[offset:], zeros)
+	if i < 1 || pdf[offset+i-1] != '<' {
+		return byteRange, contents, errors.New("signature placeholder not found")
+	}
+	contents = [2]int{offset + i - 1, offset + i + len(zeros) + 1}
+
+	j := bytes.Index(pdf[offset:], []byte("/ByteRange"))
+	if j == -1 {
+		return byteRange, contents, errors.New("byte range placeholder not found")
+	}
+	start := offset + j + bytes.IndexByte(pdf[offset+j:], '[')
+	end := start + bytes.IndexByte(pdf[start:], ']') + 1
+	return [2]int{start, end}, contents, nil
+}
+
+// formatByteRange writes a /ByteRange array padded with spaces to width
+func formatByteRange(r [4]int, width int) []byte {
+	b := []byte("[")
+	for i, v := range r {
+		if i > 0 {
+			b = append(b, ' ')
+		}
+		b = strconv.AppendInt(b, int64(v), 10)
+	}
+	b = append(b, ']')
+	return append(b, bytes.Repeat([]byte(" "), width-len(b))...)
+}
+
+// cms builds the detached CAdES signature of data, timestamped when a TSA is
+// configured
+func (s *Signer) cms(ctx context.Context, data []byte) ([]byte, error) {
+	sd, err := pkcs7.NewSignedData(data)
+	if err != nil {
+		return nil, err
+	}
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err := sd.AddSignerChainPAdES(s.Certificate, s.Key, nil, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, fmt.Errorf("failed to sign PDF: %w", err)
+	}
+	for _, cert := range s.Chain {
+		sd.AddCertificate(cert)
+	}
+	if s.TSAURL != "" {
+		err := sd.RequestSignerTimestampToken(0, func(signature []byte) ([]byte, error) {
+			return s.timestamp(ctx, signature)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sd.Detach()
+	return sd.Finish()
+}
+
+// timestampResponse is the part of an RFC 3161 TimeStampResp that is kept
+type timestampResponse struct {
+	Status         asn1.RawValue
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// timestamp asks the TSA for a token over the signature value
+func (s *Signer) timestamp(ctx context.Context, signature []byte) ([]byte, error) {
+	req, err := timestamp.CreateRequest(bytes.NewReader(signature), &timestamp.RequestOptions{
+		Hash:         crypto.SHA256,
+		Certificates: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.TSAURL, bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/timestamp-query")
+	client := s.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTSATimeout}
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach TSA: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("TSA answered %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTSAResponse))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TSA response: %w", err)
+	}
+
+	ts, err := timestamp.ParseResponse(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TSA response: %w", err)
+	}
+	digest := sha256.Sum256(signature)
+	if !bytes.Equal(ts.HashedMessage, digest[:]) {
+		return nil, errors.New("TSA timestamped another signature")
+	}
+	var tsr timestampResponse
+	if _, err := asn1.Unmarshal(body, &tsr); err != nil {
+		return nil, fmt.Errorf("invalid TSA response: %w", err)
+	}
+	return tsr.TimeStampToken.FullBytes, nil
+}
@@ -0,0 +1,262 @@
+package to_pdf
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
+	"main/data"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gen2brain/go-fitz"
+	"github.com/unidoc/pkcs7"
+	"github.com/unidoc/timestamp"
+)
+
+// testCertificate issues a certificate for key, self-signed when parent is nil
+func testCertificate(t *testing.T, name string, key *ecdsa.PrivateKey, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, usage ...x509.ExtKeyUsage) *x509.Certificate {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           usage,
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	return cert
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	return key
+}
+
+// newTestTSA starts a local RFC 3161 time stamping authority
+func newTestTSA(t *testing.T) *httptest.Server {
+	t.Helper()
+	key := newTestKey(t)
+	cert := testCertificate(t, "Test TSA", key, nil, nil, x509.ExtKeyUsageTimeStamping)
+
+	tsa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req, err := timestamp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ts := timestamp.Timestamp{
+			HashAlgorithm:     req.HashAlgorithm,
+			HashedMessage:     req.HashedMessage,
+			Time:              time.Now(),
+			Policy:            asn1.ObjectIdentifier{1, 2, 3, 4, 1},
+			Nonce:             req.Nonce,
+			AddTSACertificate: req.Certificates,
+		}
+		resp, err := ts.CreateResponse(cert, key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/timestamp-reply")
+		w.Write(resp)
+	}))
+	t.Cleanup(tsa.Close)
+	return tsa
+}
+
+// newTestSigner returns a signer whose certificate is issued by a test CA
+func newTestSigner(t *testing.T) (*Signer, *x509.Certificate) {
+	t.Helper()
+	caKey := newTestKey(t)
+	ca := testCertificate(t, "Test CA", caKey, nil, nil)
+	key := newTestKey(t)
+	cert := testCertificate(t, "Health Plan", key, ca, caKey, x509.ExtKeyUsageAny)
+	return &Signer{Certificate: cert, Key: key}, ca
+}
+
+var byteRangePattern = regexp.MustCompile(`/ByteRange\s*\[\s*(\d+) (\d+) (\d+) (\d+)\s*\]`)
+
+// verifySignature checks the last signature of pdf and returns its CMS
+func verifySignature(t *testing.T, pdf []byte, roots *x509.CertPool) *pkcs7.PKCS7 {
+	t.Helper()
+	matches := byteRangePattern.FindAllSubmatch(pdf, -1)
+	if len(matches) == 0 {
+		t.Fatal("no signature byte range")
+	}
+	var r [4]int
+	for i := range r {
+		r[i], _ = strconv.Atoi(string(matches[len(matches)-1][i+1]))
+	}
+	if r[0] != 0 || r[2]+r[3] != len(pdf) {
+		t.Fatalf("byte range %v does not cover the file of %d bytes", r, len(pdf))
+	}
+
+	contents, err := hex.DecodeString(string(pdf[r[1]+1 : r[2]-1]))
+	if err != nil {
+		t.Fatalf("invalid signature contents: %v", err)
+	}
+	var der asn1.RawValue
+	if _, err := asn1.Unmarshal(contents, &der); err != nil {
+		t.Fatalf("invalid signature contents: %v", err)
+	}
+	p7, err := pkcs7.Parse(der.FullBytes)
+	if err != nil {
+		t.Fatalf("pkcs7.Parse() error = %v", err)
+	}
+	p7.Content = append(append([]byte{}, pdf[:r[1]]...), pdf[r[2]:]...)
+	if err := p7.VerifyWithChain(roots); err != nil {
+		t.Fatalf("signature does not verify: %v", err)
+	}
+	return p7
+}
+
+func TestGeneratePDFSigned(t *testing.T) {
+	signer, ca := newTestSigner(t)
+	signer.TSAURL = newTestTSA(t).URL
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	signer.Chain = []*x509.Certificate{ca}
+
+	idCardsResp := data.IdCardsResponseSchema{Data: []data.IdCard{data.MockImageIdCardFront}}
+	opts := Options{Renderer: pdfRenderer{pdf: minimalPDF()}, Signer: signer, Archival: true}
+	resp, err := GeneratePDF(context.Background(), idCardsResp, opts)
+	if err != nil {
+		t.Fatalf("GeneratePDF() error = %v", err)
+	}
+
+	p7 := verifySignature(t, resp.PDFContent, roots)
+	if got := p7.GetOnlySigner(); got == nil || !got.Equal(signer.Certificate) {
+		t.Error("signature is not made with the issuer certificate")
+	}
+	timestamped := false
+	for _, attr := range p7.Signers[0].UnauthenticatedAttributes {
+		timestamped = timestamped || attr.Type.Equal(pkcs7.OIDAttributeTimeStampToken)
+	}
+	if !timestamped {
+		t.Error("signature has no timestamp token")
+	}
+
+	// Signing appends to the archival file without breaking it
+	if err := CheckPDFAMarkers(resp.PDFContent); err != nil {
+		t.Errorf("CheckPDFAMarkers() error = %v", err)
+	}
+	doc, err := fitz.NewFromMemory(resp.PDFContent)
+	if err != nil {
+		t.Fatalf("fitz.NewFromMemory() error = %v", err)
+	}
+	doc.Close()
+
+	// Any change after signing invalidates the signature
+	tampered := bytes.Replace(resp.PDFContent, []byte("/MediaBox [0 0 612 792]"), []byte("/MediaBox [0 0 612 793]"), 1)
+	if bytes.Equal(tampered, resp.PDFContent) {
+		t.Fatal("test PDF has no media box to tamper with")
+	}
+	r := byteRangePattern.FindAllSubmatch(tampered, -1)
+	start, _ := strconv.Atoi(string(r[len(r)-1][2]))
+	end, _ := strconv.Atoi(string(r[len(r)-1][3]))
+	p7.Content = append(append([]byte{}, tampered[:start]...), tampered[end:]...)
+	if err := p7.Verify(); err == nil {
+		t.Error("tampered PDF still verifies")
+	}
+}
+
+func TestGeneratePDFSignedWithoutTSA(t *testing.T) {
+	signer, ca := newTestSigner(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	signer.Chain = []*x509.Certificate{ca}
+
+	opts := Options{Renderer: pdfRenderer{pdf: minimalPDF()}, Signer: signer}
+	resp, err := GeneratePDF(context.Background(), data.IdCardsResponseSchema{}, opts)
+	if err != nil {
+		t.Fatalf("GeneratePDF() error = %v", err)
+	}
+	verifySignature(t, resp.PDFContent, roots)
+}
+
+func TestGeneratePDFSignedTSAFailure(t *testing.T) {
+	tsa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer tsa.Close()
+	signer, _ := newTestSigner(t)
+	signer.TSAURL = tsa.URL
+
+	opts := Options{Renderer: pdfRenderer{pdf: minimalPDF()}, Signer: signer}
+	if _, err := GeneratePDF(context.Background(), data.IdCardsResponseSchema{}, opts); err == nil {
+		t.Error("GeneratePDF() signed without the timestamp")
+	}
+}
+
+func TestGeneratePDFSignedEncryption(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	opts := Options{Renderer: &fakeRenderer{}, Signer: signer, Encryption: &Encryption{}}
+	if _, err := GeneratePDF(context.Background(), data.IdCardsResponseSchema{}, opts); !errors.Is(err, ErrSignedEncryption) {
+		t.Errorf("GeneratePDF() error = %v, want ErrSignedEncryption", err)
+	}
+}
+
+func TestLoadSigner(t *testing.T) {
+	signer, ca := newTestSigner(t)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(signer.Key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	certPEM := append(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: signer.Certificate.Raw}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})...)
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadSigner(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("LoadSigner() error = %v", err)
+	}
+	if !loaded.Certificate.Equal(signer.Certificate) || len(loaded.Chain) != 1 || !loaded.Chain[0].Equal(ca) {
+		t.Error("LoadSigner() did not load the certificate chain")
+	}
+	if _, err := LoadSigner(keyFile, keyFile); err == nil {
+		t.Error("LoadSigner() accepted a key as certificate")
+	}
+}
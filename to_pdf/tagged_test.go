@@ -0,0 +1,93 @@
+//go:build chrome
+
+package to_pdf
+
+import (
+	"context"
+	"main/data"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// TestChromedpTaggedPDF checks the structure Chrome writes into the PDF
+// itself: run with go test -tags chrome ./to_pdf on a machine with Chrome
+func TestChromedpTaggedPDF(t *testing.T) {
+	card := data.MockImageIdCardFront
+	resp, err := GeneratePDF(context.Background(), data.IdCardsResponseSchema{Data: []data.IdCard{card}}, Options{
+		Renderer: NewChromedpRenderer(),
+		Title:    "Member ID cards",
+		Lang:     "en-US",
+	})
+	if err != nil {
+		t.Fatalf("GeneratePDF() error = %v", err)
+	}
+
+	ctx, err := readPDF(resp.PDFContent)
+	if err != nil {
+		t.Fatalf("failed to read PDF: %v", err)
+	}
+	root, err := ctx.Catalog()
+	if err != nil {
+		t.Fatalf("failed to read catalog: %v", err)
+	}
+
+	if lang := infoString(root, "Lang"); lang != "en-US" {
+		t.Errorf("catalog /Lang = %q, want %q", lang, "en-US")
+	}
+	info, err := infoDict(ctx)
+	if err != nil || info == nil {
+		t.Errorf("no document information dictionary: %v", err)
+	} else if title := infoString(info, "Title"); title != "Member ID cards" {
+		t.Errorf("/Title = %q, want %q", title, "Member ID cards")
+	}
+	if markInfo, err := ctx.DereferenceDict(root["MarkInfo"]); err != nil || markInfo == nil {
+		t.Error("catalog has no /MarkInfo")
+	} else if marked := markInfo.BooleanEntry("Marked"); marked == nil || !*marked {
+		t.Error("/MarkInfo is not /Marked")
+	}
+
+	tree, err := ctx.DereferenceDict(root["StructTreeRoot"])
+	if err != nil || tree == nil {
+		t.Fatalf("catalog has no /StructTreeRoot: %v", err)
+	}
+	figures := figureAlts(ctx, tree["K"], map[int]bool{})
+	want := strings.Join(strings.Fields(card.Attributes.AltText), " ")
+	for _, alt := range figures {
+		if strings.Join(strings.Fields(alt), " ") == want {
+			return
+		}
+	}
+	t.Errorf("no /Figure has the card AltText as /Alt, figures have %q", figures)
+}
+
+// figureAlts walks the structure tree below kids and returns the /Alt of
+// every /Figure element
+func figureAlts(ctx *model.Context, kids types.Object, seen map[int]bool) []string {
+	if ref, ok := kids.(types.IndirectRef); ok {
+		if seen[ref.ObjectNumber.Value()] {
+			return nil
+		}
+		seen[ref.ObjectNumber.Value()] = true
+	}
+	obj, err := ctx.Dereference(kids)
+	if err != nil || obj == nil {
+		return nil
+	}
+
+	var alts []string
+	switch k := obj.(type) {
+	case types.Array:
+		for _, kid := range k {
+			alts = append(alts, figureAlts(ctx, kid, seen)...)
+		}
+	case types.Dict:
+		if s := k.NameEntry("S"); s != nil && *s == "Figure" {
+			alts = append(alts, infoString(k, "Alt"))
+		}
+		alts = append(alts, figureAlts(ctx, k["K"], seen)...)
+	}
+	return alts
+}
@@ -0,0 +1,51 @@
+//go:build chrome
+
+package to_pdf
+
+import (
+	"context"
+	"main/data"
+	"strings"
+	"testing"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// TestChromedpTextLayer checks the text layer makes it into the PDF Chrome
+// writes, so the member ID can be searched for and copied: run with
+// go test -tags chrome ./to_pdf on a machine with Chrome
+func TestChromedpTextLayer(t *testing.T) {
+	card := data.MockImageIdCardFront
+	for _, layout := range []Layout{LayoutStacked, LayoutPrint} {
+		resp, err := GeneratePDF(context.Background(), data.IdCardsResponseSchema{Data: []data.IdCard{card}}, Options{
+			Renderer:  NewChromedpRenderer(),
+			Layout:    layout,
+			TextLayer: true,
+		})
+		if err != nil {
+			t.Fatalf("%s: GeneratePDF() error = %v", layout, err)
+		}
+
+		doc, err := fitz.NewFromMemory(resp.PDFContent)
+		if err != nil {
+			t.Fatalf("%s: fitz.NewFromMemory() error = %v", layout, err)
+		}
+		text, err := doc.Text(0)
+		doc.Close()
+		if err != nil {
+			t.Fatalf("%s: Text() error = %v", layout, err)
+		}
+
+		// Compare words, as line breaks and spacing depend on the layout
+		got := strings.Join(strings.Fields(text), " ")
+		for _, line := range strings.Split(card.Attributes.AltText, "\n") {
+			want := strings.Join(strings.Fields(line), " ")
+			if want != "" && !strings.Contains(got, want) {
+				t.Errorf("%s: page text is missing %q, got %q", layout, want, got)
+			}
+		}
+		if !strings.Contains(got, "Member ID: 123456789") {
+			t.Errorf("%s: page text has no member ID, got %q", layout, got)
+		}
+	}
+}
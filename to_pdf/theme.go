@@ -0,0 +1,177 @@
+package to_pdf
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Names of the built-in themes
+const (
+	ThemeDefault      = "default"
+	ThemePrint        = "print"
+	ThemeHighContrast = "high-contrast"
+)
+
+// builtinThemes are the themes shipped in the templates directory
+var builtinThemes = []string{ThemeDefault, ThemePrint, ThemeHighContrast}
+
+// ErrBuiltinTheme is returned for a tenant theme named after a built-in theme,
+// which would replace it for every tenant
+var ErrBuiltinTheme = errors.New("theme name is reserved for a built-in theme")
+
+// Page templates of a theme. Themes only define the templates they change,
+// the rest come from the default theme.
+const (
+	// templatePage renders the stacked and paired layouts
+	templatePage = "page"
+	// templatePrintPage renders the CR80 print layout
+	templatePrintPage = "print-page"
+)
+
+// themeFiles matches the template files of a theme directory
+const themeFiles = "*.tmpl"
+
+//go:embed templates
+var builtinTemplates embed.FS
+
+// Theme is a set of html/template templates the PDF page is built from. The
+// page templates use the "card", "image-card" and "html-card" partials for the
+// card faces and the "page-style" and "print-style" templates for their CSS.
+type Theme struct {
+	name string
+	tmpl *template.Template
+}
+
+// Name returns the name the theme is registered under
+func (t *Theme) Name() string {
+	return t.name
+}
+
+var templateFuncs = template.FuncMap{
+	// mm formats a length in millimetres for CSS
+	"mm": func(v float64) template.CSS {
+		return template.CSS(fmt.Sprintf("%.2fmm", v))
+	},
+}
+
+// baseTemplates are the default theme templates. They are never executed so
+// that every theme, including the default one, can be cloned from them.
+var baseTemplates = template.Must(template.New(ThemeDefault).Funcs(templateFuncs).
+	ParseFS(builtinTemplates, "templates/"+ThemeDefault+"/"+themeFiles))
+
+// parseTheme parses the templates matching patterns in fsys on top of a copy of
+// the default theme templates
+func parseTheme(name string, fsys fs.FS, patterns ...string) (*Theme, error) {
+	tmpl, err := baseTemplates.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy default theme: %w", err)
+	}
+	if tmpl, err = tmpl.ParseFS(fsys, patterns...); err != nil {
+		return nil, fmt.Errorf("failed to parse theme %q: %w", name, err)
+	}
+	return &Theme{name: name, tmpl: tmpl}, nil
+}
+
+// LoadTheme reads the *.tmpl files of dir into a theme named name. Templates
+// the directory does not define are taken from the default theme.
+func LoadTheme(name, dir string) (*Theme, error) {
+	return parseTheme(name, os.DirFS(dir), themeFiles)
+}
+
+// LoadThemes registers every subdirectory of dir as a theme named after the
+// subdirectory, so each tenant can ship its own templates. Subdirectories named
+// after a built-in theme are rejected with ErrBuiltinTheme. It returns the
+// names of the registered themes.
+func LoadThemes(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read theme directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if slices.Contains(builtinThemes, entry.Name()) {
+			return names, fmt.Errorf("%w: %s", ErrBuiltinTheme, filepath.Join(dir, entry.Name()))
+		}
+		theme, err := LoadTheme(entry.Name(), filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return names, err
+		}
+		RegisterTheme(theme)
+		names = append(names, theme.Name())
+	}
+	return names, nil
+}
+
+// execute renders the named template of the theme
+func (t *Theme) execute(name string, data any) (string, error) {
+	var sb strings.Builder
+	if err := t.tmpl.ExecuteTemplate(&sb, name, data); err != nil {
+		return "", fmt.Errorf("failed to render theme %q: %w", t.name, err)
+	}
+	return sb.String(), nil
+}
+
+var (
+	themesMu sync.RWMutex
+	themes   = map[string]*Theme{}
+)
+
+func init() {
+	for _, name := range builtinThemes {
+		theme, err := parseTheme(name, builtinTemplates, "templates/"+name+"/"+themeFiles)
+		if err != nil {
+			panic(err)
+		}
+		RegisterTheme(theme)
+	}
+}
+
+// RegisterTheme makes a theme available by name, replacing any theme
+// previously registered under the same name
+func RegisterTheme(t *Theme) {
+	themesMu.Lock()
+	defer themesMu.Unlock()
+	themes[t.name] = t
+}
+
+// GetTheme returns the theme registered under name
+func GetTheme(name string) (*Theme, error) {
+	themesMu.RLock()
+	defer themesMu.RUnlock()
+	t, ok := themes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown theme %q", name)
+	}
+	return t, nil
+}
+
+// ThemeNames returns the names of all registered themes in sorted order
+func ThemeNames() []string {
+	themesMu.RLock()
+	defer themesMu.RUnlock()
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultTheme returns the theme used when none is specified
+func DefaultTheme() *Theme {
+	// Registered by init and never removed
+	t, _ := GetTheme(ThemeDefault)
+	return t
+}
@@ -0,0 +1,118 @@
+package to_pdf
+
+import (
+	"context"
+	"errors"
+	"main/data"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinThemes(t *testing.T) {
+	idCardsResp := data.IdCardsResponseSchema{
+		Data: []data.IdCard{data.MockImageIdCardFront, data.MockHTMLIdCardBack},
+	}
+
+	for _, name := range []string{ThemeDefault, ThemePrint, ThemeHighContrast} {
+		t.Run(name, func(t *testing.T) {
+			theme, err := GetTheme(name)
+			if err != nil {
+				t.Fatalf("GetTheme(%q) error = %v", name, err)
+			}
+
+			for _, layout := range []Layout{LayoutStacked, LayoutPaired, LayoutPrint} {
+				renderer := &fakeRenderer{}
+				_, err := GeneratePDF(context.Background(), idCardsResp, Options{Renderer: renderer, Layout: layout, Theme: theme})
+				if err != nil {
+					t.Fatalf("GeneratePDF(%s) error = %v", layout, err)
+				}
+				html := string(renderer.html)
+				if !strings.Contains(html, `<img src="data:image/png;base64,`) || !strings.Contains(html, "Mock ID Card Back") {
+					t.Errorf("%s layout is missing a card face", layout)
+				}
+			}
+		})
+	}
+
+	highContrast, _ := GetTheme(ThemeHighContrast)
+	html, err := buildHTML(highContrast, documentData{}, idCardsResp, LayoutStacked)
+	if err != nil {
+		t.Fatalf("buildHTML() error = %v", err)
+	}
+	if !strings.Contains(html, "border: 3px solid #000") {
+		t.Error("high-contrast theme does not use its own styles")
+	}
+}
+
+func TestLoadThemes(t *testing.T) {
+	dir := t.TempDir()
+	tenant := filepath.Join(dir, "acme")
+	if err := os.Mkdir(tenant, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	partial := `{{define "image-card"}}<figure class="acme-card"><img src="{{.Src}}" alt="{{.AltText}}"></figure>{{end}}`
+	if err := os.WriteFile(filepath.Join(tenant, "cards.tmpl"), []byte(partial), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := LoadThemes(dir)
+	if err != nil {
+		t.Fatalf("LoadThemes() error = %v", err)
+	}
+	if len(names) != 1 || names[0] != "acme" {
+		t.Fatalf("LoadThemes() = %v, want [acme]", names)
+	}
+
+	theme, err := GetTheme("acme")
+	if err != nil {
+		t.Fatalf("GetTheme() error = %v", err)
+	}
+	card := data.MockImageIdCardFront
+	card.Attributes.AltText = `Front <of> card`
+	html, err := buildHTML(theme, documentData{}, data.IdCardsResponseSchema{Data: []data.IdCard{card}}, LayoutStacked)
+	if err != nil {
+		t.Fatalf("buildHTML() error = %v", err)
+	}
+	if !strings.Contains(html, `<figure class="acme-card">`) || !strings.Contains(html, `alt="Front &lt;of&gt; card"`) {
+		t.Errorf("tenant partial not used or not escaped:\n%s", html)
+	}
+	if !strings.Contains(html, "font-family: Arial") {
+		t.Error("tenant theme does not inherit the default page styles")
+	}
+}
+
+func TestLoadThemeInvalidTemplate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "page.tmpl"), []byte(`{{define "page"}}{{.Missing`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTheme("broken", dir); err == nil {
+		t.Error("LoadTheme() accepted an invalid template")
+	}
+}
+
+func TestLoadThemesRejectsBuiltinNames(t *testing.T) {
+	for _, name := range []string{ThemeDefault, ThemePrint, ThemeHighContrast} {
+		dir := t.TempDir()
+		if err := os.Mkdir(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		page := `{{define "page"}}tenant page{{end}}`
+		if err := os.WriteFile(filepath.Join(dir, name, "page.tmpl"), []byte(page), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := LoadThemes(dir); !errors.Is(err, ErrBuiltinTheme) {
+			t.Errorf("LoadThemes() with a %q directory error = %v, want ErrBuiltinTheme", name, err)
+		}
+		theme, err := GetTheme(name)
+		if err != nil {
+			t.Fatalf("GetTheme(%q) error = %v", name, err)
+		}
+		if html, _ := buildHTML(theme, documentData{}, data.IdCardsResponseSchema{Data: []data.IdCard{data.MockImageIdCardFront}}, LayoutStacked); strings.Contains(html, "tenant page") {
+			t.Errorf("built-in theme %q was replaced by the tenant directory", name)
+		}
+	}
+}
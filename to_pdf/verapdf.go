@@ -0,0 +1,85 @@
+package to_pdf
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// VeraPDF validates archival PDFs against PDF/A-2b with the veraPDF command
+// line validator, covering what CheckPDFAMarkers does not look at
+type VeraPDF struct {
+	path string
+}
+
+// NewVeraPDF returns a validator running the verapdf executable at path,
+// looked up in the PATH when it has no directory
+func NewVeraPDF(path string) (*VeraPDF, error) {
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("veraPDF not found: %w", err)
+	}
+	return &VeraPDF{path: resolved}, nil
+}
+
+// veraPDFReport is the part of a veraPDF machine readable report (--format
+// mrr) that says whether the file is compliant and which rules failed
+type veraPDFReport struct {
+	Reports []struct {
+		Profile   string `xml:"profileName,attr"`
+		Compliant bool   `xml:"isCompliant,attr"`
+		Rules     []struct {
+			Clause      string `xml:"clause,attr"`
+			Status      string `xml:"status,attr"`
+			Description string `xml:"description"`
+		} `xml:"details>rule"`
+	} `xml:"jobs>job>validationReport"`
+}
+
+// Validate runs veraPDF over pdf and fails with ErrNotPDFA listing the failed
+// rules when it is not PDF/A-2b compliant
+func (v *VeraPDF) Validate(ctx context.Context, pdf []byte) error {
+	dir, err := os.MkdirTemp("", "verapdf")
+	if err != nil {
+		return fmt.Errorf("failed to write PDF for veraPDF: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cards.pdf")
+	if err := os.WriteFile(path, pdf, 0o600); err != nil {
+		return fmt.Errorf("failed to write PDF for veraPDF: %w", err)
+	}
+
+	// veraPDF exits non-zero for non-compliant files, so the report decides
+	out, runErr := exec.CommandContext(ctx, v.path, "--flavour", "2b", "--format", "mrr", path).Output()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var report veraPDFReport
+	if err := xml.NewDecoder(bytes.NewReader(out)).Decode(&report); err != nil || len(report.Reports) == 0 {
+		return fmt.Errorf("veraPDF did not validate the PDF: %v", runErr)
+	}
+
+	var violations []string
+	for _, r := range report.Reports {
+		if r.Compliant {
+			continue
+		}
+		for _, rule := range r.Rules {
+			if rule.Status == "failed" {
+				violations = append(violations, fmt.Sprintf("clause %s: %s", rule.Clause, strings.TrimSpace(rule.Description)))
+			}
+		}
+		if len(violations) == 0 {
+			violations = append(violations, "not compliant with "+r.Profile)
+		}
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("%w: %s", ErrNotPDFA, strings.Join(violations, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+//go:build verapdf
+
+package to_pdf
+
+import (
+	"context"
+	"main/data"
+	"testing"
+	"time"
+)
+
+// TestVeraPDF runs veraPDF over archival output, which CheckPDFAMarkers only
+// partly covers: run with go test -tags verapdf ./to_pdf on a machine with
+// verapdf and Chrome on the PATH
+func TestVeraPDF(t *testing.T) {
+	validator, err := NewVeraPDF("verapdf")
+	if err != nil {
+		t.Fatalf("NewVeraPDF() error = %v", err)
+	}
+	idCardsResp := data.IdCardsResponseSchema{
+		Data: []data.IdCard{data.MockImageIdCardFront, data.MockImageIdCardBack, data.MockHTMLIdCardBoth},
+	}
+	signer, ca := newTestSigner(t)
+	signer.Chain = append(signer.Chain, ca)
+	signer.TSAURL = newTestTSA(t).URL
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"rendered", Options{Renderer: DefaultRenderer()}},
+		// Signing appends to the archival file, which has to stay conformant
+		{"rendered and signed", Options{Renderer: DefaultRenderer(), Signer: signer}},
+		{"fixed and signed", Options{Renderer: pdfRenderer{pdf: minimalPDF()}, Signer: signer}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Archival = true
+			tt.opts.Validator = validator
+			if _, err := GeneratePDF(context.Background(), idCardsResp, tt.opts); err != nil {
+				t.Errorf("GeneratePDF() error = %v", err)
+			}
+		})
+	}
+
+	t.Run("converted images", func(t *testing.T) {
+		pdf := testPDF("<< /XObject << /Im1 5 0 R >> >>",
+			"<< /Type /XObject /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Interpolate true /Length 3 >>\nstream\n\x00\x00\x00\nendstream")
+		converted, err := convertToPDFA(pdf, archiveMetadata{Title: "ID Cards", IssueDate: time.Now()})
+		if err != nil {
+			t.Fatalf("convertToPDFA() error = %v", err)
+		}
+		if err := validator.Validate(context.Background(), converted); err != nil {
+			t.Errorf("Validate() error = %v", err)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package to_pdf
+
+import (
+	"context"
+	"errors"
+	"main/data"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeVeraPDF returns a validator running a script that prints report
+func fakeVeraPDF(t *testing.T, report string) *VeraPDF {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "report.xml"), []byte(report), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "verapdf")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncat '"+filepath.Join(dir, "report.xml")+"'\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewVeraPDF(script)
+	if err != nil {
+		t.Fatalf("NewVeraPDF() error = %v", err)
+	}
+	return v
+}
+
+// veraPDFReportXML returns a machine readable veraPDF report with the given rules
+func veraPDFReportXML(compliant bool, rules string) string {
+	return `<?xml version="1.0" encoding="utf-8"?>
+<report><jobs><job><item><name>cards.pdf</name></item>
+<validationReport jobEndStatus="normal" profileName="PDF/A-2B validation profile" isCompliant="` + strconv.FormatBool(compliant) + `">
+<details>` + rules + `</details></validationReport></job></jobs></report>`
+}
+
+const failedRule = `<rule specification="ISO 19005-2:2011" clause="6.2.4.3" testNumber="2" status="failed" failedChecks="1"><description>DeviceRGB shall only be used with an RGB output intent</description></rule>`
+
+func TestVeraPDFValidate(t *testing.T) {
+	passed := `<rule specification="ISO 19005-2:2011" clause="6.1.2" testNumber="1" status="passed" passedChecks="1"><description>Header</description></rule>`
+
+	if err := fakeVeraPDF(t, veraPDFReportXML(true, passed)).Validate(context.Background(), minimalPDF()); err != nil {
+		t.Errorf("Validate() of a compliant report error = %v", err)
+	}
+
+	err := fakeVeraPDF(t, veraPDFReportXML(false, passed+failedRule)).Validate(context.Background(), minimalPDF())
+	if !errors.Is(err, ErrNotPDFA) || !strings.Contains(err.Error(), "clause 6.2.4.3: DeviceRGB") || strings.Contains(err.Error(), "Header") {
+		t.Errorf("Validate() of a failed report error = %v, want the failed rule", err)
+	}
+
+	err = fakeVeraPDF(t, "Exception: not a PDF").Validate(context.Background(), minimalPDF())
+	if err == nil || errors.Is(err, ErrNotPDFA) {
+		t.Errorf("Validate() without a report error = %v, want a veraPDF failure", err)
+	}
+
+	if _, err := NewVeraPDF(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("NewVeraPDF() accepted a missing executable")
+	}
+}
+
+func TestGeneratePDFArchivalValidator(t *testing.T) {
+	idCardsResp := data.IdCardsResponseSchema{Data: []data.IdCard{data.MockImageIdCardFront}}
+	validator := fakeVeraPDF(t, veraPDFReportXML(false, failedRule))
+
+	opts := Options{Renderer: pdfRenderer{pdf: minimalPDF()}, Archival: true, Validator: validator}
+	if _, err := GeneratePDF(context.Background(), idCardsResp, opts); !errors.Is(err, ErrNotPDFA) {
+		t.Errorf("GeneratePDF() with a failing validator error = %v, want ErrNotPDFA", err)
+	}
+
+	// Only archival PDFs claim PDF/A conformance
+	opts.Archival = false
+	if _, err := GeneratePDF(context.Background(), idCardsResp, opts); err != nil {
+		t.Errorf("GeneratePDF() of a non-archival PDF error = %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package to_pdf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/SebastiaanKlippert/go-wkhtmltopdf"
+)
+
+// WkhtmltopdfRenderer renders PDFs with the wkhtmltopdf binary. Its PDFs are
+// not tagged, so screen readers cannot navigate them or read the card AltText;
+// it is meant for print and for deployments without Chrome.
+type WkhtmltopdfRenderer struct{}
+
+// NewWkhtmltopdfRenderer creates a renderer backed by wkhtmltopdf
+func NewWkhtmltopdfRenderer() *WkhtmltopdfRenderer {
+	return &WkhtmltopdfRenderer{}
+}
+
+func (r *WkhtmltopdfRenderer) Name() string {
+	return RendererWkhtmltopdf
+}
+
+func (r *WkhtmltopdfRenderer) Render(ctx context.Context, html []byte, page Page) ([]byte, error) {
+	pdfg, err := wkhtmltopdf.NewPDFGenerator()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize PDF generator: %w", err)
+	}
+
+	pdfg.Dpi.Set(300)
+	if page.Width == letterWidthMM && page.Height == letterHeightMM {
+		pdfg.PageSize.Set(wkhtmltopdf.PageSizeLetter)
+	} else {
+		pdfg.PageWidth.Set(uint(math.Round(page.Width)))
+		pdfg.PageHeight.Set(uint(math.Round(page.Height)))
+	}
+	margin := uint(math.Round(page.Margin))
+	pdfg.MarginTop.Set(margin)
+	pdfg.MarginBottom.Set(margin)
+	pdfg.MarginLeft.Set(margin)
+	pdfg.MarginRight.Set(margin)
+
+	pageReader := wkhtmltopdf.NewPageReader(bytes.NewReader(html))
+	// Card markup is sanitised, this keeps anything that slips through from
+	// running or reading local files
+	pageReader.DisableJavascript.Set(true)
+	pageReader.DisableLocalFileAccess.Set(true)
+	if page.Exact {
+		pageReader.DisableSmartShrinking.Set(true)
+	}
+	pdfg.AddPage(pageReader)
+
+	if err = pdfg.CreateContext(ctx); err != nil {
+		return nil, fmt.Errorf("failed to generate PDF: %w", err)
+	}
+	return pdfg.Bytes(), nil
+}